@@ -0,0 +1,121 @@
+//go:build !cgo
+// +build !cgo
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"activator/internal"
+)
+
+// -----------------------------------------------------------------------------
+// hwid 诊断子命令：仅执行系统信息采集并输出结果，不访问 API、不消耗令牌
+// -----------------------------------------------------------------------------
+
+// hwidSlotReport 单个槽位的诊断输出
+type hwidSlotReport struct {
+	Slot        string   `json:"slot"`
+	Value       string   `json:"value"`
+	Source      string   `json:"source"`
+	Failures    []string `json:"failures"`
+	NeedElevate bool     `json:"need_elevate"`
+}
+
+// hwidReport 诊断输出
+type hwidReport struct {
+	SysInfoSpec        string           `json:"sysinfo_spec"`
+	KeySchedule        string           `json:"key_schedule"`
+	Platform           string           `json:"platform"`
+	Elevated           bool             `json:"elevated"`
+	Masked             bool             `json:"masked"`
+	Slots              []hwidSlotReport `json:"slots"`
+	BindKeyFingerprint string           `json:"bind_key_fingerprint"`
+}
+
+// runHwidCommand 执行 `activator hwid [--json] [--unmask]`
+func runHwidCommand(args []string) {
+	fs := flag.NewFlagSet("hwid", flag.ExitOnError)
+	jsonOut := fs.Bool("json", false, "以 JSON 格式输出")
+	unmask := fs.Bool("unmask", false, "显示完整的规范化值（默认掩码）")
+	_ = fs.Parse(args)
+
+	results := internal.CollectSysInfoReport(context.Background())
+	infos := internal.SysInfoValues(results)
+	// 与激活流程相同的密钥编排与输入，指纹对应本地状态实际使用的硬件绑定密钥
+	version, keySchedule, err := newKeySchedule(infos, internal.DetectEnvironment(infos).Cloud)
+	if err != nil {
+		printError("安全组件初始化失败", err)
+	}
+	bindKey, err := keySchedule.Derive(internal.KeyPurposeStateEncryption)
+	keySchedule.Wipe()
+	if err != nil {
+		printError("安全组件初始化失败", err)
+	}
+	fingerprint := internal.BindKeyFingerprint(bindKey)
+	internal.WipeBytes(bindKey)
+
+	report := hwidReport{
+		SysInfoSpec:        version.SysInfoSpec(),
+		KeySchedule:        string(version),
+		Platform:           fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH),
+		Elevated:           os.Getenv("ELEVATE_FLAG") == "1",
+		Masked:             !*unmask,
+		BindKeyFingerprint: fingerprint,
+	}
+	for i, r := range results {
+		value := r.Value
+		if !*unmask {
+			value = internal.MaskSysInfo(value)
+		}
+		failures := []string{}
+		for _, f := range r.Failures {
+			if !*unmask {
+				f = internal.MaskFailure(f)
+			}
+			failures = append(failures, f)
+		}
+		report.Slots = append(report.Slots, hwidSlotReport{
+			Slot:        fmt.Sprintf("info%d", i+1),
+			Value:       value,
+			Source:      r.Source,
+			Failures:    failures,
+			NeedElevate: r.NeedElevate,
+		})
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			printError("输出失败", err)
+		}
+		return
+	}
+
+	printBanner()
+	infoColor.Printf("系统信息规范: %s   密钥编排: %s   平台: %s   已提权: %v\n\n", report.SysInfoSpec, report.KeySchedule, report.Platform, report.Elevated)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "槽位\t值\t来源\t需提权\t失败原因")
+	for _, s := range report.Slots {
+		source := s.Source
+		if source == "" {
+			source = "-"
+		}
+		failures := "-"
+		if len(s.Failures) > 0 {
+			failures = strings.Join(s.Failures, " | ")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%v\t%s\n", s.Slot, s.Value, source, s.NeedElevate, failures)
+	}
+	_ = tw.Flush()
+	fmt.Println()
+	infoColor.Printf("绑定密钥指纹 (%s, %s): %s\n", report.KeySchedule, internal.KeyPurposeStateEncryption, report.BindKeyFingerprint)
+}
@@ -7,17 +7,20 @@
 package main
 
 import (
-	"bytes"
-	"crypto"
-	"crypto/rsa"
-	"encoding/base64"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
 	"runtime/debug"
 	"strings"
+	"syscall"
 	"time"
 
 	"activator/internal"
@@ -36,9 +39,18 @@ const Version = "1.0.0"
 
 var (
 	// 编译时通过 -ldflags 注入盐值，以强化密钥衍生：
-	// go build -ldflags "-X main.SaltForPrivateKeyEncryption=$(openssl rand -hex 16) -X main.ApiURL=https://your.api.url -s -w -buildmode=pie -linkmode=external -extldflags='-Wl,-zrelro,-znow'"
+	// go build -ldflags "-X main.SaltForPrivateKeyEncryption=$(openssl rand -hex 16) -X main.ApiURL=https://your.api.url -X main.ServerVerifyKey=<base64 Ed25519 公钥> -s -w -buildmode=pie -linkmode=external -extldflags='-Wl,-zrelro,-znow'"
+	// ServerVerifyKey 为服务端响应签名的验证公钥（由 tools/gensigningkey 生成），未通过验证的响应不会被执行
 	SaltForPrivateKeyEncryption string
 	ApiURL                      string
+	ServerVerifyKey             string
+	// 盐值代号与密钥编排版本同样在编译时注入：-X main.SaltGeneration=<代号> -X main.KeyScheduleVersion=legacy-hmac
+	// KeyScheduleVersion 留空时使用 HKDF 编排，legacy-hmac 保留给需沿用旧版派生的构建
+	SaltGeneration     string
+	KeyScheduleVersion string
+	// 可接受的最低密钥协商算法：-X main.MinKeyAgreement=<算法标识>，留空时为 x25519-hkdf-sha256-aes256gcm；
+	// 默认构建不会回退 RSA、拒绝无挑战接口的旧服务端，只有过渡期需兼容旧服务端时才指定 rsa-oaep-sha256（发布顺序见 README）
+	MinKeyAgreement string
 
 	// 配色方案（Material Design inspired)
 	titleColor    = color.New(color.FgHiCyan)
@@ -132,6 +144,9 @@ func updateProgress(bar *progressbar.ProgressBar, step, totalSteps int, message
 
 // printError 统一错误处理，输出到屏幕 + 日志，并安全退出
 func printError(prefix string, err error) {
+	// os.Exit 不执行 defer：先清零所有敏感数据
+	internal.WipeAllSecrets()
+
 	// 彩色输出
 	errorColor.Printf("\n⚠ %s\n", prefix)
 	detailColor.Printf("  %v\n", err)
@@ -177,34 +192,92 @@ func printError(prefix string, err error) {
 }
 
 // -----------------------------------------------------------------------------
-// 内存擦除辅助函数（覆盖敏感字节）
+// 敏感数据保护：锁定内存中的密钥在任何退出路径上都被清零
 // -----------------------------------------------------------------------------
 
-func wipe(b []byte) {
-	for i := range b {
-		b[i] = 0
+// wipeSecretsOnSignal 收到中断或终止信号时清零敏感数据后退出
+func wipeSecretsOnSignal() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigCh
+		internal.WipeAllSecrets()
+		logger.Warnw("收到信号，已清除敏感数据并退出", "signal", sig.String())
+		logger.Sync()
+		os.Exit(130)
+	}()
+}
+
+// newKeySchedule 按编译时注入的配置建立密钥编排；激活流程与 hwid 诊断共用，保证指纹对应实际使用的密钥。
+// 云厂商规则中的易变槽位不参与派生
+func newKeySchedule(infos []string, cloud *internal.CloudInfo) (internal.KeyScheduleVersion, *internal.KeySchedule, error) {
+	version, err := internal.ParseKeyScheduleVersion(KeyScheduleVersion)
+	if err != nil {
+		return version, nil, fmt.Errorf("配置无效: %w", err)
+	}
+	if SaltForPrivateKeyEncryption == "" {
+		return version, nil, errMissingSalt
 	}
+	ks, err := internal.NewKeySchedule(version, []byte(SaltForPrivateKeyEncryption), SaltGeneration, internal.BindingSysInfo(infos, cloud))
+	return version, ks, err
 }
 
+var errMissingSalt = errors.New("构建时未注入 SaltForPrivateKeyEncryption")
+
 // -----------------------------------------------------------------------------
 // 主程序入口
 // -----------------------------------------------------------------------------
 
 func main() {
+	// -------------------- 子命令 --------------------
+	if len(os.Args) > 1 && os.Args[1] == internal.HwidHelperArg {
+		// 特权辅助模式：只采集硬件槽位并交还父进程
+		os.Exit(internal.RunHwidHelper(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "hwid" {
+		runHwidCommand(os.Args[2:])
+		return
+	}
+
+	// 禁止核心转储与 ptrace 读取内存；正常返回与 panic 时清零敏感数据
+	if err := internal.HardenProcess(); err != nil {
+		logger.Warnw("进程加固失败", "error", err)
+	}
+	defer internal.WipeAllSecrets()
+
 	printBanner()
 
 	// -------------------- 解析命令行参数 --------------------
 	installSessionToken := flag.String("token", "", "安装会话令牌")
+	elevationFlag := flag.String("elevation", os.Getenv(internal.ElevationPolicyEnv), "提权策略: auto（默认）、never、require")
 	flag.Parse()
 
 	if *installSessionToken == "" {
 		printError("配置无效", fmt.Errorf("请提供安装会话令牌 (使用 --token 参数)"))
 	}
+	if SaltForPrivateKeyEncryption == "" {
+		printError("配置无效", errMissingSalt)
+	}
+	elevationPolicy, err := internal.ParseElevationPolicy(*elevationFlag)
+	if err != nil {
+		printError("配置无效", err)
+	}
 
 	// -------------------- 环境变量检查 -> 改为检查编译时注入的 API 地址 --------------------
 	if ApiURL == "" {
 		printError("配置无效", fmt.Errorf("程序编译不完整：缺失 API 地址"))
 	}
+	if ServerVerifyKey == "" {
+		printError("配置无效", fmt.Errorf("程序编译不完整：缺失服务端验证公钥"))
+	}
+	minKeyAgreement, err := internal.ParseMinKeyAgreement(MinKeyAgreement)
+	if err != nil {
+		printError("配置无效", err)
+	}
+	serverVerifyKey, err := internal.ParseServerVerifyKey(ServerVerifyKey)
+	if err != nil {
+		printError("配置无效", err)
+	}
 
 	// -------------------- 初始化进度条 --------------------
 	const totalSteps = 8
@@ -213,12 +286,43 @@ func main() {
 
 	// -------------------- 步骤 1：收集系统信息 --------------------
 	updateProgress(bar, 1, totalSteps, "正在收集系统信息")
-	standardizedSysInfo := internal.GetStandardizedSysInfoV1_1()
+	// 各槽位并发采集且各自限时；Ctrl+C 可中断采集
+	collectCtx, stopCollect := signal.NotifyContext(context.Background(), os.Interrupt)
+	slotResults, elevation, err := internal.CollectSysInfoWithPolicy(collectCtx, elevationPolicy)
+	stopCollect()
+	if err != nil {
+		printError("权限不足", err)
+	}
+	// 采集结束后才接管中断信号，采集期间 Ctrl+C 仍只取消采集
+	wipeSecretsOnSignal()
+	standardizedSysInfo := internal.SysInfoValues(slotResults)
+	for _, s := range elevation.Slots {
+		logger.Warnw("槽位未以特权采集", "slot", s.Slot, "reason", s.Reason)
+	}
+	logger.Infow("提权", "mode", elevation.Mode, "outcome", elevation.Outcome, "method", elevation.Method)
 
 	// -------------------- 步骤 2：派生密钥 --------------------
 	updateProgress(bar, 2, totalSteps, "正在初始化安全组件")
-	salt := []byte(SaltForPrivateKeyEncryption)
-	_ = internal.DeriveSysInfoBindKeyV1_1(salt)
+	// 云主机的易变槽位不参与状态密钥，停机/启动后重试状态与私钥仍可读取
+	environment := internal.DetectEnvironment(standardizedSysInfo)
+	scheduleVersion, keySchedule, err := newKeySchedule(standardizedSysInfo, environment.Cloud)
+	if err != nil {
+		printError("安全组件初始化失败", err)
+	}
+	// 硬件绑定的状态密钥只用于密封本地状态，从不发送
+	stateKeyRaw, err := keySchedule.Derive(internal.KeyPurposeStateEncryption)
+	keySchedule.Wipe()
+	if err != nil {
+		printError("安全组件初始化失败", err)
+	}
+	stateKey := internal.SecretFrom(stateKeyRaw)
+	store := openStateStore(stateKey.Bytes())
+	stateKey.Destroy()
+	// fail 记录重试状态后退出
+	fail := func(stage, prefix string, err error) {
+		recordRetry(store, *installSessionToken, stage, err)
+		printError(prefix, err)
+	}
 
 	// -------------------- 步骤 3：验证系统环境 --------------------
 	updateProgress(bar, 3, totalSteps, "正在验证系统环境")
@@ -233,84 +337,137 @@ func main() {
 		printError("环境检查失败", fmt.Errorf("无法获取必要的系统信息，请检查程序权限"))
 	}
 
-	// -------------------- 步骤 4：生成密钥对 --------------------
+	// -------------------- 步骤 4：协商并生成密钥 --------------------
 	updateProgress(bar, 4, totalSteps, "正在生成安全密钥")
-	privKey, err := internal.GenerateRSAKeyPair()
+	// 挑战请求同时协商密钥协商算法；仅旧服务端需要生成 RSA-4096 密钥
+	challenge, err := internal.RequestActivationChallenge(ApiURL, *installSessionToken, serverVerifyKey, minKeyAgreement)
+	if err != nil {
+		fail("challenge", "验证失败", err)
+	}
+	if challenge.Legacy {
+		logger.Warnw("服务端不支持挑战接口，沿用 RSA-OAEP；响应签名不绑定挑战，无法防止重放")
+	}
+	unwrapper, err := newSessionKeyUnwrapper(store, challenge.KeyAgreement)
+	if err != nil {
+		printError("密钥生成失败", err)
+	}
+	clientPublicKey, err := unwrapper.PublicKey()
 	if err != nil {
 		printError("密钥生成失败", err)
 	}
-	pubKeyPEM, _ := internal.EncodePublicKeyToPEM(&privKey.PublicKey)
+	logger.Infow("密钥协商", "algorithm", unwrapper.Algorithm())
 
 	// -------------------- 步骤 5：准备验证信息 --------------------
 	updateProgress(bar, 5, totalSteps, "正在准备验证信息")
-	pubKeyBase64 := base64.StdEncoding.EncodeToString(pubKeyPEM)
 	platformInfo := fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH)
+	// 原始硬件标识不出本机：仅发送以本令牌挑战盐值计算的 HMAC，不同令牌之间无法关联
+	hardwareSalt, err := challenge.HardwareIDSalt()
+	if err != nil {
+		printError("安全组件初始化失败", err)
+	}
+	hardwareSlots := internal.ComputeSlotHashes(standardizedSysInfo, hardwareSalt)
+	internal.ApplyCloudBindingPolicy(hardwareSlots, environment.Cloud)
+	logger.Infow("运行环境", "kind", environment.Kind, "hypervisor", environment.Hypervisor, "container", environment.Container)
 	apiReq := &internal.ActivateMachineRequest{
 		InstallSessionToken: *installSessionToken,
-		HardwareIDs:         standardizedSysInfo,
-		ClientPublicKey:     pubKeyBase64,
-		PlatformInfo:        platformInfo + ",SysInfoSpec=" + internal.SysInfoSpecVersion,
+		HardwareIDs:         internal.BlindHardwareIDs(standardizedSysInfo, hardwareSalt),
+		HardwareSlots:       hardwareSlots,
+		HardwareSaltID:      internal.BindKeyFingerprint(hardwareSalt),
+		ClientPublicKey:     clientPublicKey,
+		KeyAgreement:        unwrapper.Algorithm(),
+		ScriptAADVersions:   internal.SupportedScriptAADVersions,
+		PlatformInfo:        platformInfo + ",SysInfoSpec=" + scheduleVersion.SysInfoSpec() + ",SysInfoBlocklist=" + internal.SysInfoBlocklistVersion,
+		Environment:         &environment,
+		Elevation:           &elevation,
 	}
 
 	// -------------------- 步骤 6：调用激活 API --------------------
 	updateProgress(bar, 6, totalSteps, "正在验证客户端")
 	apiResp, err := internal.CallActivateMachineAPI(ApiURL, apiReq)
 	if err != nil {
-		printError("验证失败", err)
+		var mismatch *internal.HardwareMismatchError
+		if errors.As(err, &mismatch) {
+			diagnoseHardwareMismatch(store, standardizedSysInfo, environment.Cloud, mismatch)
+		}
+		fail("activate", "验证失败", err)
 	}
 
 	// -------------------- 步骤 7：处理响应数据 --------------------
 	updateProgress(bar, 7, totalSteps, "正在处理响应数据")
-	logger.Debugw("解密API响应中的SSK", "encryptedSSK_base64", apiResp.EncryptedSessionScriptKey)
+	// 先验证服务端签名：未通过验证的响应不解密、不执行；签名绑定令牌与本次挑战，旧响应无法重放
+	if err := internal.VerifyActivateResponse(serverVerifyKey, apiResp, challenge.Binding(clientPublicKey, *installSessionToken)); err != nil {
+		fail("verify", "数据验证失败", err)
+	}
 
 	encryptedSSK, err := internal.DecodeBase64String(apiResp.EncryptedSessionScriptKey)
 	if err != nil {
-		printError("数据处理失败 (SSK Base64解码)", err)
+		fail("decrypt", "数据处理失败 (SSK Base64解码)", err)
 	}
-	encryptedScript, err := internal.DecodeBase64String(apiResp.EncryptedScriptBlob)
+	// 按协商的算法解包SSK（旧服务端不回传 key_agreement，即 RSA-OAEP，须为最低算法所允许）
+	if alg, err := internal.NegotiateKeyAgreement(apiResp.KeyAgreement, minKeyAgreement); err != nil {
+		fail("decrypt", "数据验证失败", err)
+	} else if alg != unwrapper.Algorithm() {
+		fail("decrypt", "数据验证失败", fmt.Errorf("响应的密钥协商算法 %q 与协商结果 %q 不一致", apiResp.KeyAgreement, unwrapper.Algorithm()))
+	}
+	serverPublicKey, err := internal.DecodeBase64String(apiResp.ServerPublicKey)
 	if err != nil {
-		printError("数据处理失败 (ScriptBlob Base64解码)", err)
+		fail("decrypt", "数据处理失败 (服务端公钥Base64解码)", err)
 	}
-
-	// 使用OAEP SHA256解密SSK
-	sskRaw, err := privKey.Decrypt(nil, encryptedSSK, &rsa.OAEPOptions{Hash: crypto.SHA256, Label: nil})
+	sskRaw, err := unwrapper.UnwrapSessionKey(encryptedSSK, serverPublicKey)
+	unwrapper.Destroy()
 	if err != nil {
-		logger.Errorw("使用OAEP解密SSK失败", "error", err)
-		printError("数据解密失败 (OAEP)", err)
+		logger.Errorw("解包SSK失败", "algorithm", unwrapper.Algorithm(), "error", err)
+		fail("decrypt", "数据解密失败 ("+unwrapper.Algorithm()+")", err)
 	}
-	defer wipe(sskRaw) // 使用后立即擦除
+	ssk := internal.SecretFrom(sskRaw)
+	defer ssk.Destroy() // 使用后立即擦除
 
-	if len(encryptedScript) < 12 {
-		printError("数据验证失败", fmt.Errorf("响应数据格式无效"))
+	// 执行元数据、令牌、平台信息与规范版本作为 AAD：任一被替换，解密即失败
+	scriptAAD, err := internal.BuildScriptAAD(apiResp.ScriptAADVersion, internal.ScriptAADContext{
+		InstallSessionToken: *installSessionToken,
+		PlatformInfo:        apiReq.PlatformInfo,
+		SysInfoSpec:         scheduleVersion.SysInfoSpec(),
+	}, apiResp.ScriptExecutionMetadata)
+	if err != nil {
+		fail("decrypt", "数据验证失败", err)
+	}
+	payloadSrc, err := openPayloadSource(apiResp)
+	if err != nil {
+		fail("decrypt", "数据处理失败", err)
 	}
-	nonce := encryptedScript[:12]
-	ciphertext := encryptedScript[12:]
-	decryptedScript, err := internal.AESGCMDecrypt(sskRaw, nonce, ciphertext)
+	defer payloadSrc.Close()
+	plaintext, err := internal.OpenScriptPayload(ssk.Bytes(), payloadSrc, scriptAAD)
 	if err != nil {
-		printError("数据解密失败", err)
+		fail("decrypt", "数据解密失败", err)
 	}
-	defer wipe(decryptedScript)
+	defer plaintext.Close()
+	// 明文只在锁定内存或 0700 临时文件中落地，交付方式见 runPayload；回执所需的摘要同步计算
+	payloadHash := sha256.New()
+	payload := io.TeeReader(plaintext, payloadHash)
 
 	// -------------------- 步骤 8：执行配置脚本 --------------------
 	updateProgress(bar, 8, totalSteps, "正在完成配置")
 	meta := apiResp.ScriptExecutionMetadata
-	cmd := exec.Command(meta.Interpreter, meta.Args...)
-	cmd.Stdin = bytes.NewReader(decryptedScript)
-
-	var stdoutBuf, stderrBuf bytes.Buffer
-	cmd.Stdout = &stdoutBuf
-	cmd.Stderr = &stderrBuf
-
-	start := time.Now()
-	err = cmd.Run()
-	duration := time.Since(start)
+	run, err := runPayload(meta, payload)
+	duration := run.duration
 
 	logger.Infow("脚本执行完成", "duration", duration.String())
 
 	if err != nil {
-		errorMsg := fmt.Sprintf("配置过程发生错误:\n%s\n%s", stdoutBuf.String(), stderrBuf.String())
-		printError("配置失败", fmt.Errorf(errorMsg))
+		errorMsg := fmt.Sprintf("配置过程发生错误: %v\n%s\n%s", err, run.stdout.String(), run.stderr.String())
+		fail("execute", "配置失败", errors.New(errorMsg))
 	}
+	completeActivation(store, internal.ActivationReceipt{
+		TokenFingerprint: internal.TokenFingerprint(*installSessionToken),
+		ActivatedAt:      time.Now().UTC(),
+		Platform:         platformInfo,
+		Interpreter:      meta.Interpreter,
+		ScriptSHA256:     hex.EncodeToString(payloadHash.Sum(nil)),
+		Duration:         duration.String(),
+
+		HardwareSalt:  hardwareSalt,
+		HardwareSlots: hardwareSlots,
+	})
 
 	// -------------------- 全部完成 --------------------
 	_ = bar.Finish()
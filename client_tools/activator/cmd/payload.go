@@ -0,0 +1,134 @@
+//go:build !cgo
+// +build !cgo
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"activator/internal"
+)
+
+// -----------------------------------------------------------------------------
+// 载荷交付：脚本完整解密校验到锁定内存后才运行；大体积载荷边下载边解密写入临时文件，
+// 仅 type_hint 为 stream 的消费者边解密边写入 stdin（见 internal.PayloadTypeHintStream）
+// -----------------------------------------------------------------------------
+
+// openPayloadSource 返回加密载荷的字节流：优先流式下载 encrypted_payload_url，否则流式解码内嵌的 base64
+func openPayloadSource(resp *internal.ActivateMachineResponse) (io.ReadCloser, error) {
+	if resp.EncryptedPayloadURL != "" {
+		return internal.OpenEncryptedPayload(resp.EncryptedPayloadURL)
+	}
+	return io.NopCloser(base64.NewDecoder(base64.StdEncoding, strings.NewReader(resp.EncryptedScriptBlob))), nil
+}
+
+// payloadRun 执行结果
+type payloadRun struct {
+	duration time.Duration
+	stdout   bytes.Buffer
+	stderr   bytes.Buffer
+}
+
+// runPayload 按执行元数据交付载荷并运行解释器
+func runPayload(meta internal.ScriptExecutionMetadata, payload io.Reader) (*payloadRun, error) {
+	run := &payloadRun{}
+	args := append([]string(nil), meta.Args...)
+	toFile := false
+	for _, a := range args {
+		toFile = toFile || strings.Contains(a, internal.PayloadPathPlaceholder)
+	}
+
+	if toFile {
+		// 载荷完整解密并通过校验后才运行解释器
+		path, err := writePayloadFile(payload)
+		if err != nil {
+			return run, err
+		}
+		defer os.Remove(path)
+		for i, a := range args {
+			args[i] = strings.ReplaceAll(a, internal.PayloadPathPlaceholder, path)
+		}
+	}
+
+	stream := !toFile && meta.TypeHint == internal.PayloadTypeHintStream
+	var script *internal.SecretBuffer
+	if !toFile && !stream {
+		// 脚本：完整解密并通过校验后才启动解释器，截断的前缀绝不会被执行
+		var err error
+		script, err = internal.ReadSecret(payload, internal.MaxDecompressedScriptSize)
+		if err != nil {
+			return run, fmt.Errorf("载荷校验失败: %w", err)
+		}
+		defer script.Destroy()
+	}
+
+	cmd := exec.Command(meta.Interpreter, args...)
+	cmd.Stdout = &run.stdout
+	cmd.Stderr = &run.stderr
+	start := time.Now()
+	defer func() { run.duration = time.Since(start) }()
+	if script != nil {
+		// bytes.Reader 实现 WriterTo，明文直接从锁定内存写入管道
+		cmd.Stdin = bytes.NewReader(script.Bytes())
+	}
+	if !stream {
+		return run, cmd.Run()
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return run, err
+	}
+	if err := cmd.Start(); err != nil {
+		return run, err
+	}
+	// 仅限可安全处理截断输入的消费者：逐段写入已通过校验的明文；一旦出现截断或篡改，立即终止解释器，不向其发送正常的 EOF
+	if err := copyPayload(stdin, payload); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return run, fmt.Errorf("载荷校验失败，已终止解释器: %w", err)
+	}
+	if err := stdin.Close(); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return run, err
+	}
+	return run, cmd.Wait()
+}
+
+// writePayloadFile 将明文流写入仅当前用户可访问的临时文件，校验失败时删除
+func writePayloadFile(payload io.Reader) (string, error) {
+	f, err := os.CreateTemp("", "activator-payload-*")
+	if err != nil {
+		return "", err
+	}
+	path := f.Name()
+	err = copyPayload(f, payload)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// 安装包等载荷可能需要直接执行
+		err = os.Chmod(path, 0700)
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("载荷校验失败: %w", err)
+	}
+	return path, nil
+}
+
+// copyPayload 以锁定内存作为复制缓冲区；屏蔽 ReaderFrom / WriterTo，避免 io.Copy 另行在堆上分配明文缓冲区
+func copyPayload(dst io.Writer, src io.Reader) error {
+	buf := internal.NewSecretBuffer(internal.StreamSegmentSize)
+	defer buf.Destroy()
+	_, err := io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, buf.Bytes())
+	return err
+}
@@ -0,0 +1,137 @@
+//go:build !cgo
+// +build !cgo
+
+package main
+
+import (
+	"crypto/rsa"
+	"errors"
+	"time"
+
+	"activator/internal"
+)
+
+// -----------------------------------------------------------------------------
+// 本地密封状态：私钥、回执与重试状态均以硬件绑定密钥加密落盘
+// -----------------------------------------------------------------------------
+
+// openStateStore 打开默认状态目录；失败时返回 nil，激活流程照常进行但不落盘任何状态
+func openStateStore(stateKey []byte) *internal.StateStore {
+	dir, err := internal.DefaultStateDir()
+	if err == nil {
+		var store *internal.StateStore
+		if store, err = internal.OpenStateStore(dir, stateKey); err == nil {
+			logger.Debugw("状态目录", "dir", dir, "state_key", internal.BindKeyFingerprint(stateKey))
+			return store
+		}
+	}
+	logger.Warnw("无法打开本地状态目录，本次不保存状态", "error", err)
+	return nil
+}
+
+// loadOrGenerateKey 优先复用上次未完成激活时密封保存的私钥，否则生成新密钥并密封保存
+func loadOrGenerateKey(store *internal.StateStore) (*rsa.PrivateKey, error) {
+	if store != nil {
+		key, err := store.LoadSealedPrivateKey()
+		switch {
+		case err == nil:
+			logger.Infow("复用已密封的私钥")
+			return key, nil
+		case errors.Is(err, internal.ErrStateUnsealFailed):
+			// 来自其他机器或硬件已变化：丢弃，不尝试任何恢复
+			logger.Warnw("已密封的私钥无法解封，重新生成", "error", err)
+		case errors.Is(err, internal.ErrStateNotFound):
+		default:
+			logger.Warnw("已密封的私钥无效，重新生成", "error", err)
+		}
+	}
+
+	key, err := internal.GenerateRSAKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	if store != nil {
+		if err := store.SealPrivateKey(key); err != nil {
+			logger.Warnw("密封保存私钥失败", "error", err)
+		}
+	}
+	return key, nil
+}
+
+// recordRetry 记录失败的阶段与原因；同一令牌的失败次数累加
+func recordRetry(store *internal.StateStore, token, stage string, cause error) {
+	if store == nil {
+		return
+	}
+	fp := internal.TokenFingerprint(token)
+	var state internal.RetryState
+	err := store.GetJSON(internal.StateRetry, &state)
+	if err != nil && !errors.Is(err, internal.ErrStateNotFound) {
+		logger.Warnw("重试状态无法读取，重新计数", "error", err)
+	}
+	if err != nil || state.TokenFingerprint != fp {
+		state = internal.RetryState{TokenFingerprint: fp}
+	}
+	state.Attempts++
+	state.LastAttempt = time.Now().UTC()
+	state.LastStage = stage
+	state.LastError = cause.Error()
+	if err := store.PutJSON(internal.StateRetry, state); err != nil {
+		logger.Warnw("保存重试状态失败", "error", err)
+	}
+}
+
+// newSessionKeyUnwrapper 按协商的算法准备客户端密钥：X25519 与混合模式为每次新生成的临时密钥，RSA 可复用密封保存的私钥
+func newSessionKeyUnwrapper(store *internal.StateStore, keyAgreement string) (internal.SessionKeyUnwrapper, error) {
+	switch keyAgreement {
+	case internal.KeyAgreementHybrid:
+		return internal.NewHybridUnwrapper()
+	case internal.KeyAgreementX25519:
+		return internal.NewX25519Unwrapper()
+	}
+	privKey, err := loadOrGenerateKey(store)
+	if err != nil {
+		return nil, err
+	}
+	return internal.NewRSAUnwrapper(privKey), nil
+}
+
+// diagnoseHardwareMismatch 服务端判定硬件不匹配时，以上次成功激活的盐值重新计算当前槽位并与回执在本地比对；
+// 服务端未返回变化的槽位时以本地结果补全
+func diagnoseHardwareMismatch(store *internal.StateStore, infos []string, cloud *internal.CloudInfo, mismatch *internal.HardwareMismatchError) {
+	if store == nil {
+		return
+	}
+	var receipt internal.ActivationReceipt
+	if err := store.GetJSON(internal.StateReceipt, &receipt); err != nil || len(receipt.HardwareSlots) == 0 {
+		return
+	}
+	if len(receipt.HardwareSalt) == 0 {
+		// 旧版回执未保存盐值，哈希不可比较
+		logger.Infow("上次激活的回执未保存硬件盐值，跳过本地比对")
+		return
+	}
+	current := internal.ComputeSlotHashes(infos, receipt.HardwareSalt)
+	internal.ApplyCloudBindingPolicy(current, cloud)
+	local := internal.EvaluateHardwareMatch(receipt.HardwareSlots, current, mismatch.Result.Policy)
+	logger.Infow("本地硬件比对", "matched", local.MatchedSlots, "changed", local.ChangedSlots, "passed", local.Passed)
+	if len(mismatch.Result.ChangedSlots) == 0 {
+		local.Passed = false
+		mismatch.Result = local
+	}
+}
+
+// completeActivation 保存回执，清除重试状态与已使用的私钥
+func completeActivation(store *internal.StateStore, receipt internal.ActivationReceipt) {
+	if store == nil {
+		return
+	}
+	if err := store.PutJSON(internal.StateReceipt, receipt); err != nil {
+		logger.Warnw("保存激活回执失败", "error", err)
+	}
+	for _, name := range []string{internal.StateRetry, internal.StatePrivateKey} {
+		if err := store.Delete(name); err != nil {
+			logger.Warnw("清除本地状态失败", "entry", name, "error", err)
+		}
+	}
+}
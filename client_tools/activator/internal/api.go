@@ -2,6 +2,7 @@ package internal
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -12,19 +13,85 @@ import (
 // 激活请求体结构体
 // 用于发送到 /api/activate-machine
 // 字段名需与后端API一致
-// client_public_key 需 base64 编码
-// hardware_ids 为字符串数组
+// client_public_key 需 base64 编码：rsa-oaep-sha256 为 PEM 公钥，x25519 为 32 字节临时公钥
+// key_agreement 为协商后的密钥协商算法标识
+// script_aad_versions 为客户端支持的脚本密文 AAD 版本
+// hardware_ids 为各槽位以本令牌挑战盐值计算的 HMAC（十六进制），不含原始序列号
+// hardware_slots 为逐槽位哈希（与 hardware_ids 一一对应），服务端按各令牌签发的盐值重新计算后模糊匹配
+// hardware_salt_id 为计算所用盐值的指纹，服务端据此确认哈希使用的是本令牌的挑战盐值
 // platform_info 可选
+// environment 为运行环境识别结果（物理机/虚拟机/容器/WSL）
+// elevation 为提权策略与结果，含因未提权而缺失的槽位及原因
 
 type ActivateMachineRequest struct {
+	InstallSessionToken string             `json:"install_session_token"`
+	HardwareIDs         []string           `json:"hardware_ids"`
+	HardwareSlots       []HardwareSlotHash `json:"hardware_slots,omitempty"`
+	HardwareSaltID      string             `json:"hardware_salt_id,omitempty"`
+	ClientPublicKey     string             `json:"client_public_key"`
+	KeyAgreement        string             `json:"key_agreement,omitempty"`
+	ScriptAADVersions   []string           `json:"script_aad_versions,omitempty"`
+	PlatformInfo        string             `json:"platform_info"`
+	Environment         *EnvironmentInfo   `json:"environment,omitempty"`
+	Elevation           *ElevationReport   `json:"elevation,omitempty"`
+}
+
+// 硬件信息挑战请求/响应结构体
+// 用于 /api/activation-challenge，服务端为每个令牌下发独立的挑战盐值（base64）
+// key_agreements 为客户端按优先顺序声明的密钥协商算法，key_agreement 为服务端所选（旧服务端不返回）
+// 旧服务端没有该接口（404）时视为未协商：沿用 RSA-OAEP，无挑战盐值；仅在编译时的最低算法允许 RSA 时接受
+// server_nonce 为服务端每次挑战新生成的随机数（base64），signature 为服务端 Ed25519 分离签名（见 response_signature.go）
+
+type ActivationChallengeRequest struct {
 	InstallSessionToken string   `json:"install_session_token"`
-	HardwareIDs         []string `json:"hardware_ids"`
-	ClientPublicKey     string   `json:"client_public_key"`
-	PlatformInfo        string   `json:"platform_info"`
+	KeyAgreements       []string `json:"key_agreements,omitempty"`
+}
+
+type ActivationChallengeResponse struct {
+	ChallengeSalt string `json:"challenge_salt"`
+	ServerNonce   string `json:"server_nonce"`
+	KeyAgreement  string `json:"key_agreement,omitempty"`
+	Signature     string `json:"signature"`
+}
+
+// ActivationChallenge 验证并解码后的挑战：盐值、服务端随机数与协商后的密钥协商算法；Legacy 表示服务端不支持挑战接口
+type ActivationChallenge struct {
+	Salt         []byte
+	ServerNonce  []byte
+	KeyAgreement string
+	Legacy       bool
+}
+
+// MinServerNonceSize 服务端随机数最小长度（字节）
+const MinServerNonceSize = 16
+
+// 错误响应体结构体
+// 硬件匹配未通过时附带 hardware_match
+
+type apiErrorResponse struct {
+	Error         string               `json:"error"`
+	HardwareMatch *HardwareMatchResult `json:"hardware_match,omitempty"`
 }
 
 // 激活响应体结构体
 // encrypted_session_script_key, encrypted_script_blob, launcher_download_url, script_execution_metadata
+// key_agreement 为包装 SSK 所用算法，server_public_key 为 ECDH 模式下服务端临时公钥（base64）
+// script_aad_version 为加密脚本时所用的 AAD 版本
+// encrypted_payload_url 非空时载荷不内嵌于 encrypted_script_blob，而是从该地址流式下载（大体积载荷使用分段 AEAD）
+// signature 为服务端 Ed25519 分离签名（base64），覆盖规范化后的响应（含 script_aad_version）、client_public_key、令牌与本次挑战
+
+// 载荷交付方式（均在解密校验之后，截断或篡改的载荷绝不会以正常 EOF 交给解释器）：
+//   - 参数中出现 PayloadPathPlaceholder：完整解密校验到仅当前用户可访问的临时文件，占位符替换为文件路径
+//   - type_hint 为 PayloadTypeHintStream：边解密边写入解释器 stdin，校验失败时强制终止解释器；
+//     解释器在校验失败前已读到并可能处理了部分明文，只用于能安全处理截断输入的消费者，
+//     例如先解包到暂存目录、以正常 EOF 与退出码为提交条件的安装程序；shell 等解释器读到一行即执行，不可使用
+//   - 其他（脚本）：完整解密并校验到锁定内存（不超过 MaxDecompressedScriptSize）后才启动解释器，经 stdin 交付
+
+// PayloadPathPlaceholder 参数中的载荷文件路径占位符
+const PayloadPathPlaceholder = "{payload}"
+
+// PayloadTypeHintStream 可安全处理截断输入、允许流式交付的载荷类型
+const PayloadTypeHintStream = "stream"
 
 type ScriptExecutionMetadata struct {
 	Interpreter string   `json:"interpreter"`
@@ -37,6 +104,11 @@ type ActivateMachineResponse struct {
 	EncryptedScriptBlob       string                  `json:"encrypted_script_blob"`
 	LauncherDownloadURL       string                  `json:"launcher_download_url"`
 	ScriptExecutionMetadata   ScriptExecutionMetadata `json:"script_execution_metadata"`
+	KeyAgreement              string                  `json:"key_agreement,omitempty"`
+	ServerPublicKey           string                  `json:"server_public_key,omitempty"`
+	ScriptAADVersion          string                  `json:"script_aad_version"`
+	EncryptedPayloadURL       string                  `json:"encrypted_payload_url,omitempty"`
+	Signature                 string                  `json:"signature"`
 }
 
 // 调用API进行激活，返回响应结构体
@@ -52,6 +124,10 @@ func CallActivateMachineAPI(apiURL string, reqBody *ActivateMachineRequest) (*Ac
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
+		var apiErr apiErrorResponse
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.HardwareMatch != nil && !apiErr.HardwareMatch.Passed {
+			return nil, &HardwareMismatchError{Message: apiErr.Error, Result: *apiErr.HardwareMatch}
+		}
 		return nil, fmt.Errorf("API请求失败: %s", string(body))
 	}
 	var apiResp ActivateMachineResponse
@@ -62,6 +138,73 @@ func CallActivateMachineAPI(apiURL string, reqBody *ActivateMachineRequest) (*Ac
 	return &apiResp, nil
 }
 
+// 获取令牌挑战，同时协商密钥协商算法；minKeyAgreement 为编译时固定的最低算法，只声明不弱于它的算法
+// 挑战响应须带有 serverVerifyKey 可验证的签名，未通过验证的挑战不参与协商
+func RequestActivationChallenge(apiURL, installSessionToken string, serverVerifyKey ed25519.PublicKey, minKeyAgreement string) (*ActivationChallenge, error) {
+	offered := AcceptableKeyAgreements(minKeyAgreement)
+	jsonBytes, err := json.Marshal(&ActivationChallengeRequest{InstallSessionToken: installSessionToken, KeyAgreements: offered})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.Post(apiURL+"/api/activation-challenge", "application/json", bytes.NewReader(jsonBytes))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		// 挑战接口被拦截同样表现为 404，是否退回 RSA 由最低算法决定
+		keyAgreement, err := NegotiateKeyAgreement("", minKeyAgreement)
+		if err != nil {
+			return nil, fmt.Errorf("服务端不支持挑战接口: %w", err)
+		}
+		return &ActivationChallenge{KeyAgreement: keyAgreement, Legacy: true}, nil
+	}
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("获取挑战失败: %s", string(body))
+	}
+	var challengeResp ActivationChallengeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&challengeResp); err != nil {
+		return nil, err
+	}
+	if err := VerifyActivationChallenge(serverVerifyKey, &challengeResp, installSessionToken, offered); err != nil {
+		return nil, fmt.Errorf("挑战响应: %w", err)
+	}
+	challenge, err := DecodeBase64String(challengeResp.ChallengeSalt)
+	if err != nil {
+		return nil, err
+	}
+	if len(challenge) < MinChallengeSaltSize {
+		return nil, fmt.Errorf("挑战盐值过短: %d 字节", len(challenge))
+	}
+	nonce, err := DecodeBase64String(challengeResp.ServerNonce)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) < MinServerNonceSize {
+		return nil, fmt.Errorf("服务端随机数过短: %d 字节", len(nonce))
+	}
+	keyAgreement, err := NegotiateKeyAgreement(challengeResp.KeyAgreement, minKeyAgreement)
+	if err != nil {
+		return nil, err
+	}
+	return &ActivationChallenge{Salt: challenge, ServerNonce: nonce, KeyAgreement: keyAgreement}, nil
+}
+
+// 流式下载加密载荷，调用方负责关闭
+func OpenEncryptedPayload(url string) (io.ReadCloser, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("下载加密载荷失败: %s: %s", resp.Status, string(body))
+	}
+	return resp.Body, nil
+}
+
 // base64解码工具
 func DecodeBase64String(s string) ([]byte, error) {
 	return base64.StdEncoding.DecodeString(s)
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"slices"
+	"strings"
+)
+
+// * 云厂商识别（仅读取本地文件，不访问元数据服务）
+// * EC2、GCE、Azure 上的 DMI UUID、主板序列号有时稳定有时会重新生成，根磁盘序列号常在停机/启动后改变；
+// * 识别出云厂商后按厂商规则把易变槽位标记为不参与绑定（服务端匹配与本地状态密钥均排除），避免云客户的绑定悄然失效。
+
+const (
+	CloudAWS   = "aws"
+	CloudGCE   = "gce"
+	CloudAzure = "azure"
+)
+
+// azureChassisAssetTag Azure 虚拟机固定的机箱资产标签
+const azureChassisAssetTag = "7783-7084-3265-9085-8269-3286-77"
+
+// CloudInfo 云厂商识别结果
+type CloudInfo struct {
+	Provider        string   `json:"provider"`
+	Signals         []string `json:"signals"`
+	NonBindingSlots []int    `json:"non_binding_slots,omitempty"`
+}
+
+// cloudNonBindingSlots 各厂商的易变槽位（0 起始）
+var cloudNonBindingSlots = map[string][]int{
+	// EC2：实例 UUID 与主板序列号跟随实例，EBS/实例存储的磁盘序列号随卷或宿主变化
+	CloudAWS: {4},
+	// GCE：实例 UUID 稳定，持久磁盘序列号为 persistent-disk-N，不具备唯一性
+	CloudGCE: {4},
+	// Azure：重新部署后主板序列号与磁盘序列号都会变化，仅 VM UUID 相对稳定
+	CloudAzure: {1, 4},
+}
+
+// DetectCloud 识别云厂商，非云环境返回 nil
+func DetectCloud() *CloudInfo {
+	return defaultEnvProbe.detectCloud()
+}
+
+func (p envProbe) detectCloud() *CloudInfo {
+	dmi := func(name string) string {
+		return strings.TrimSpace(p.read("sys/class/dmi/id/" + name))
+	}
+	hvUUID := strings.ToLower(strings.TrimSpace(p.read("sys/hypervisor/uuid")))
+	assetTag := dmi("chassis_asset_tag")
+	biosVendor := dmi("bios_vendor")
+	sysVendor := dmi("sys_vendor")
+
+	var provider string
+	var signals []string
+	switch {
+	case strings.HasPrefix(hvUUID, "ec2"):
+		provider = CloudAWS
+		signals = append(signals, "hypervisor_uuid:ec2")
+	case assetTag == "Amazon EC2" || sysVendor == "Amazon EC2" || biosVendor == "Amazon EC2":
+		provider = CloudAWS
+		signals = append(signals, "dmi:amazon_ec2")
+	case sysVendor == "Google" || biosVendor == "Google":
+		provider = CloudGCE
+		signals = append(signals, "dmi:google")
+	case assetTag == azureChassisAssetTag:
+		provider = CloudAzure
+		signals = append(signals, "chassis_asset_tag:azure")
+	default:
+		return nil
+	}
+	return &CloudInfo{
+		Provider:        provider,
+		Signals:         signals,
+		NonBindingSlots: cloudNonBindingSlots[provider],
+	}
+}
+
+// ApplyCloudBindingPolicy 将云厂商规则中的易变槽位标记为不参与绑定（权重置 0）
+func ApplyCloudBindingPolicy(slots []HardwareSlotHash, cloud *CloudInfo) {
+	if cloud == nil {
+		return
+	}
+	for i := range slots {
+		for _, s := range cloud.NonBindingSlots {
+			if slots[i].Slot == s {
+				slots[i].Weight = 0
+				slots[i].NonBinding = true
+			}
+		}
+	}
+}
+
+// BindingSysInfo 派生硬件绑定密钥所用的槽位值：易变槽位替换为占位符，
+// 云主机停机/启动后根磁盘等槽位变化时，本地密封状态仍可解密
+func BindingSysInfo(infos []string, cloud *CloudInfo) []string {
+	out := slices.Clone(infos)
+	if cloud == nil {
+		return out
+	}
+	for _, s := range cloud.NonBindingSlots {
+		if s >= 0 && s < len(out) {
+			out[s] = SysInfoUnavailable
+		}
+	}
+	return out
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestBindingSysInfoStableAcrossCloudRestart(t *testing.T) {
+	before := []string{"EC2A1B2C3D4E5F60", SysInfoUnavailable, "AMAZONEC2", "T3.MEDIUM", "VOL0ABC123"}
+	after := slices.Clone(before)
+	after[4] = "VOL0DEF456" // 停机/启动后根卷序列号变化
+	aws := &CloudInfo{Provider: CloudAWS, NonBindingSlots: cloudNonBindingSlots[CloudAWS]}
+
+	bound := BindingSysInfo(before, aws)
+	if bound[4] != SysInfoUnavailable || before[4] != "VOL0ABC123" {
+		t.Fatalf("易变槽位应替换为占位符且不修改原切片: %q / %q", bound, before)
+	}
+	if got := BindingSysInfo(before, nil); !slices.Equal(got, before) {
+		t.Fatalf("非云主机应原样返回: %q", got)
+	}
+
+	stateKey := func(infos []string, cloud *CloudInfo) []byte {
+		ks, err := NewKeySchedule(KeyScheduleHKDFV1, []byte("salt"), "", BindingSysInfo(infos, cloud))
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, err := ks.Derive(KeyPurposeStateEncryption)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	if !bytes.Equal(stateKey(before, aws), stateKey(after, aws)) {
+		t.Fatal("云主机根卷变化后状态密钥应不变")
+	}
+	if bytes.Equal(stateKey(before, nil), stateKey(after, nil)) {
+		t.Fatal("非云主机的根盘变化应改变状态密钥")
+	}
+}
+
+func TestDetectCloudFixtures(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		provider string
+		signal   string
+		slots    []int
+	}{
+		{
+			name: "EC2 Xen：hypervisor uuid",
+			files: map[string]string{
+				"sys/hypervisor/uuid":         "ec2e1916-9099-7caf-fd21-012345abcdef\n",
+				"sys/class/dmi/id/sys_vendor": "Xen\n",
+			},
+			provider: CloudAWS, signal: "hypervisor_uuid:ec2", slots: []int{4},
+		},
+		{
+			name: "EC2 Nitro：DMI 厂商",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":        "Amazon EC2\n",
+				"sys/class/dmi/id/bios_vendor":       "Amazon EC2\n",
+				"sys/class/dmi/id/chassis_asset_tag": "Amazon EC2\n",
+				"sys/class/dmi/id/product_name":      "m5.large\n",
+			},
+			provider: CloudAWS, signal: "dmi:amazon_ec2", slots: []int{4},
+		},
+		{
+			name: "GCE",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":   "Google\n",
+				"sys/class/dmi/id/bios_vendor":  "Google\n",
+				"sys/class/dmi/id/product_name": "Google Compute Engine\n",
+			},
+			provider: CloudGCE, signal: "dmi:google", slots: []int{4},
+		},
+		{
+			name: "Azure：机箱资产标签",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":        "Microsoft Corporation\n",
+				"sys/class/dmi/id/product_name":      "Virtual Machine\n",
+				"sys/class/dmi/id/chassis_asset_tag": azureChassisAssetTag + "\n",
+			},
+			provider: CloudAzure, signal: "chassis_asset_tag:azure", slots: []int{1, 4},
+		},
+		{
+			name: "本地 Hyper-V 不是 Azure",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":        "Microsoft Corporation\n",
+				"sys/class/dmi/id/product_name":      "Virtual Machine\n",
+				"sys/class/dmi/id/chassis_asset_tag": "0000-0000-0000-0000-0000-0000-00\n",
+			},
+		},
+		{
+			name: "本地 KVM",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":   "QEMU\n",
+				"sys/class/dmi/id/product_name": "Standard PC (Q35 + ICH9, 2009)\n",
+			},
+		},
+		{
+			name: "Xen 非 EC2",
+			files: map[string]string{
+				"sys/hypervisor/uuid":         "4a6b9c2d-1e3f-5a7b-9c0d-2e4f6a8b0c1d\n",
+				"sys/class/dmi/id/sys_vendor": "Xen\n",
+			},
+		},
+		{
+			name:  "物理机",
+			files: map[string]string{"sys/class/dmi/id/sys_vendor": "Dell Inc.\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSysTree(t)
+			for rel, data := range tt.files {
+				s.write(rel, data)
+			}
+			cloud := envProbe{root: s.root}.detectCloud()
+			if tt.provider == "" {
+				if cloud != nil {
+					t.Fatalf("非云环境不应识别出云厂商: %+v", cloud)
+				}
+				return
+			}
+			if cloud == nil || cloud.Provider != tt.provider || !slices.Equal(cloud.Signals, []string{tt.signal}) {
+				t.Fatalf("期望 %s (%s), 得到 %+v", tt.provider, tt.signal, cloud)
+			}
+			if !slices.Equal(cloud.NonBindingSlots, tt.slots) {
+				t.Fatalf("易变槽位: 期望 %v, 得到 %v", tt.slots, cloud.NonBindingSlots)
+			}
+		})
+	}
+}
+
+func TestApplyCloudBindingPolicy(t *testing.T) {
+	infos := []string{"UUID0001", "BOARD001", "VENDOR", "MODEL", "DISK0001"}
+	tests := []struct {
+		name  string
+		cloud *CloudInfo
+		masks []int
+	}{
+		{name: "aws", cloud: &CloudInfo{Provider: CloudAWS, NonBindingSlots: cloudNonBindingSlots[CloudAWS]}, masks: []int{4}},
+		{name: "gce", cloud: &CloudInfo{Provider: CloudGCE, NonBindingSlots: cloudNonBindingSlots[CloudGCE]}, masks: []int{4}},
+		{name: "azure", cloud: &CloudInfo{Provider: CloudAzure, NonBindingSlots: cloudNonBindingSlots[CloudAzure]}, masks: []int{1, 4}},
+		{name: "非云虚拟机", cloud: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := ComputeSlotHashes(infos, []byte("k"))
+			slots := ComputeSlotHashes(infos, []byte("k"))
+			ApplyCloudBindingPolicy(slots, tt.cloud)
+			for i, h := range slots {
+				masked := slices.Contains(tt.masks, i)
+				if h.Hash != want[i].Hash {
+					t.Fatalf("槽位 %d 的哈希不应改变", i)
+				}
+				if masked && (h.Weight != 0 || !h.NonBinding) {
+					t.Fatalf("槽位 %d 应不参与绑定: %+v", i, h)
+				}
+				if !masked && h != want[i] {
+					t.Fatalf("槽位 %d 应保持不变: 期望 %+v, 得到 %+v", i, want[i], h)
+				}
+			}
+			bound := BindingSysInfo(infos, tt.cloud)
+			for i, v := range bound {
+				if masked := slices.Contains(tt.masks, i); masked != (v == SysInfoUnavailable) {
+					t.Fatalf("BindingSysInfo 槽位 %d: %q", i, v)
+				}
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
+	"math/big"
 )
 
 // 生成RSA密钥对（4096位）
@@ -27,15 +28,39 @@ func EncodePublicKeyToPEM(pub *rsa.PublicKey) ([]byte, error) {
 	return pem.EncodeToMemory(block), nil
 }
 
-// 将RSA私钥编码为PEM格式
-func EncodePrivateKeyToPEM(priv *rsa.PrivateKey) ([]byte, error) {
+// 将RSA私钥编码为PEM格式（结果位于锁定内存，中间的 DER 字节已擦除）
+func EncodePrivateKeyToPEM(priv *rsa.PrivateKey) (*SecretBuffer, error) {
 	privBytes := x509.MarshalPKCS1PrivateKey(priv)
+	defer WipeBytes(privBytes)
 	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: privBytes}
-	return pem.EncodeToMemory(block), nil
+	return SecretFrom(pem.EncodeToMemory(block)), nil
+}
+
+// 擦除RSA私钥的秘密分量（尽力而为：crypto/rsa 内部预计算的副本无法访问）
+func WipeRSAPrivateKey(priv *rsa.PrivateKey) {
+	if priv == nil {
+		return
+	}
+	ints := append([]*big.Int{priv.D, priv.Precomputed.Dp, priv.Precomputed.Dq, priv.Precomputed.Qinv}, priv.Primes...)
+	for _, n := range ints {
+		if n == nil {
+			continue
+		}
+		words := n.Bits()
+		for i := range words {
+			words[i] = 0
+		}
+		n.SetInt64(0)
+	}
 }
 
 // AES-GCM加密
 func AESGCMEncrypt(key, plaintext []byte) ([]byte, []byte, error) {
+	return AESGCMEncryptWithAAD(key, plaintext, nil)
+}
+
+// AES-GCM加密（附带关联数据）
+func AESGCMEncryptWithAAD(key, plaintext, aad []byte) ([]byte, []byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, nil, err
@@ -49,11 +74,11 @@ func AESGCMEncrypt(key, plaintext []byte) ([]byte, []byte, error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	ciphertext := aesgcm.Seal(nil, nonce, plaintext, nil)
+	ciphertext := aesgcm.Seal(nil, nonce, plaintext, aad)
 	return nonce, ciphertext, nil
 }
 
-// HMAC-SHA256 派生密钥
+// HMAC-SHA256 派生密钥（旧版派生，仅用于 KeyScheduleLegacy；新代码使用 KeySchedule）
 func DeriveKeyFromHWIDs(hwids []string, salt []byte) []byte {
 	h := hmac.New(sha256.New, salt)
 	for _, hwid := range hwids {
@@ -64,6 +89,11 @@ func DeriveKeyFromHWIDs(hwids []string, salt []byte) []byte {
 
 // AES-GCM解密
 func AESGCMDecrypt(key, nonce, ciphertext []byte) ([]byte, error) {
+	return AESGCMDecryptWithAAD(key, nonce, ciphertext, nil)
+}
+
+// AES-GCM解密（校验关联数据）
+func AESGCMDecryptWithAAD(key, nonce, ciphertext, aad []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -72,18 +102,40 @@ func AESGCMDecrypt(key, nonce, ciphertext []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	plaintext, err := aesgcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := aesgcm.Open(nil, nonce, ciphertext, aad)
 	if err != nil {
 		return nil, err
 	}
 	return plaintext, nil
 }
 
+// AES-GCM解密（校验关联数据），明文直接写入锁定内存
+func AESGCMDecryptSecret(key, nonce, ciphertext, aad []byte) (*SecretBuffer, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aesgcm.Overhead() {
+		return nil, errors.New("密文过短")
+	}
+	out := NewSecretBuffer(len(ciphertext) - aesgcm.Overhead())
+	if _, err := aesgcm.Open(out.Bytes()[:0], nonce, ciphertext, aad); err != nil {
+		out.Destroy()
+		return nil, err
+	}
+	return out, nil
+}
+
 // PEM解码RSA私钥
 func DecodePEMToPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
 	block, _ := pem.Decode(pemBytes)
 	if block == nil || block.Type != "RSA PRIVATE KEY" {
 		return nil, errors.New("PEM解码失败")
 	}
+	defer WipeBytes(block.Bytes)
 	return x509.ParsePKCS1PrivateKey(block.Bytes)
 }
@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// * 运行环境识别
+// * 区分物理机、虚拟机、容器与 WSL：克隆的虚拟机共享 DMI 值，容器内通常全部槽位不可用，
+// * 结果随激活请求上报，由服务端策略或脚本元数据决定是否拒绝或区别处理。
+
+const (
+	EnvBareMetal = "bare_metal"
+	EnvVM        = "vm"
+	EnvContainer = "container"
+	EnvWSL       = "wsl"
+)
+
+// EnvironmentInfo 环境识别结果
+type EnvironmentInfo struct {
+	Kind       string     `json:"kind"`
+	Hypervisor string     `json:"hypervisor,omitempty"`
+	Container  string     `json:"container,omitempty"`
+	Signals    []string   `json:"signals,omitempty"`
+	Cloud      *CloudInfo `json:"cloud,omitempty"`
+}
+
+// dmiHypervisorVendors DMI 厂商/型号片段（已规范化为大写无空格）到虚拟化平台
+var dmiHypervisorVendors = []struct{ Fragment, Name string }{
+	{"QEMU", "qemu"},
+	{"KVM", "kvm"},
+	{"VMWARE", "vmware"},
+	{"VIRTUALBOX", "virtualbox"},
+	{"INNOTEK", "virtualbox"},
+	{"XEN", "xen"},
+	{"VIRTUALMACHINE", "hyperv"},
+	{"PARALLELS", "parallels"},
+	{"BOCHS", "bochs"},
+	{"BHYVE", "bhyve"},
+	{"OPENSTACK", "openstack"},
+	{"AMAZONEC2", "aws"},
+	{"GOOGLECOMPUTEENGINE", "gce"},
+}
+
+// cgroupContainerMarkers /proc/1/cgroup 中的容器运行时标记
+var cgroupContainerMarkers = []struct{ Marker, Name string }{
+	{"kubepods", "kubernetes"},
+	{"docker", "docker"},
+	{"libpod", "podman"},
+	{"containerd", "containerd"},
+	{"lxc", "lxc"},
+}
+
+// envProbe 以 root 为前缀读取文件，便于在合成目录树上运行
+type envProbe struct {
+	root string
+}
+
+var defaultEnvProbe = envProbe{root: "/"}
+
+// DetectEnvironment 识别当前运行环境；infos 为已采集的槽位值（非 Linux 平台据此判断厂商/型号）
+func DetectEnvironment(infos []string) EnvironmentInfo {
+	vendors := []string{}
+	if len(infos) == SysInfoSlotCount {
+		vendors = append(vendors, infos[2], infos[3])
+	}
+	if runtime.GOOS != "linux" {
+		return classifyEnvironment(vendors, nil)
+	}
+	return defaultEnvProbe.detect(vendors)
+}
+
+func (p envProbe) read(rel string) string {
+	b, err := os.ReadFile(filepath.Join(p.root, rel))
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func (p envProbe) exists(rel string) bool {
+	_, err := os.Stat(filepath.Join(p.root, rel))
+	return err == nil
+}
+
+func (p envProbe) detect(vendors []string) EnvironmentInfo {
+	for _, f := range []string{"sys_vendor", "product_name", "bios_vendor", "board_vendor"} {
+		if v := p.read("sys/class/dmi/id/" + f); v != "" {
+			vendors = append(vendors, v)
+		}
+	}
+	var signals []string
+	env := EnvironmentInfo{}
+
+	if strings.Contains(strings.ToLower(p.read("proc/version")), "microsoft") {
+		signals = append(signals, "proc_version:microsoft")
+		env.Kind = EnvWSL
+	}
+
+	switch {
+	case p.exists(".dockerenv"):
+		env.Container = "docker"
+		signals = append(signals, "file:/.dockerenv")
+	case p.exists("run/.containerenv"):
+		env.Container = "podman"
+		signals = append(signals, "file:/run/.containerenv")
+	}
+	cgroup := p.read("proc/1/cgroup")
+	for _, m := range cgroupContainerMarkers {
+		if strings.Contains(cgroup, m.Marker) {
+			if env.Container == "" {
+				env.Container = m.Name
+			}
+			signals = append(signals, "cgroup:"+m.Marker)
+			break
+		}
+	}
+
+	if cpuHasHypervisorFlag(p.read("proc/cpuinfo")) {
+		signals = append(signals, "cpuinfo:hypervisor")
+	}
+
+	res := classifyEnvironment(vendors, signals)
+	if env.Kind == EnvWSL {
+		res.Kind = EnvWSL
+	} else if env.Container != "" {
+		res.Kind = EnvContainer
+	}
+	res.Container = env.Container
+	res.Cloud = p.detectCloud()
+	return res
+}
+
+// classifyEnvironment 按 DMI 厂商字符串与已收集信号分类（不含容器/WSL 判断）
+func classifyEnvironment(vendors []string, signals []string) EnvironmentInfo {
+	env := EnvironmentInfo{Kind: EnvBareMetal, Signals: signals}
+	for _, v := range vendors {
+		norm := strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "\n", "").Replace(v))
+		for _, hv := range dmiHypervisorVendors {
+			if strings.Contains(norm, hv.Fragment) {
+				env.Kind = EnvVM
+				if env.Hypervisor == "" {
+					env.Hypervisor = hv.Name
+				}
+				if !slices.Contains(env.Signals, "dmi:"+hv.Name) {
+					env.Signals = append(env.Signals, "dmi:"+hv.Name)
+				}
+				break
+			}
+		}
+	}
+	for _, s := range signals {
+		if s == "cpuinfo:hypervisor" {
+			env.Kind = EnvVM
+		}
+	}
+	return env
+}
+
+// cpuHasHypervisorFlag /proc/cpuinfo 的 flags 行是否包含 hypervisor
+func cpuHasHypervisorFlag(cpuinfo string) bool {
+	for _, line := range strings.Split(cpuinfo, "\n") {
+		key, val, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(key) != "flags" {
+			continue
+		}
+		for _, f := range strings.Fields(val) {
+			if f == "hypervisor" {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+)
+
+const (
+	cpuinfoHypervisor = "processor\t: 0\nvendor_id\t: GenuineIntel\nflags\t\t: fpu vme de pse tsc msr pae hypervisor lahf_lm\n"
+	cpuinfoBareMetal  = "processor\t: 0\nvendor_id\t: GenuineIntel\nflags\t\t: fpu vme de pse tsc msr pae vmx smx est lahf_lm\n"
+)
+
+func TestDetectEnvironmentFixtures(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      map[string]string
+		kind       string
+		hypervisor string
+		container  string
+		signals    []string
+	}{
+		{
+			name: "物理机",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":   "Dell Inc.\n",
+				"sys/class/dmi/id/product_name": "OptiPlex 7090\n",
+				"sys/class/dmi/id/bios_vendor":  "Dell Inc.\n",
+				"proc/version":                  "Linux version 6.8.0-45-generic (buildd@lcy02-amd64-115) #45-Ubuntu SMP\n",
+				"proc/1/cgroup":                 "0::/init.scope\n",
+				"proc/cpuinfo":                  cpuinfoBareMetal,
+			},
+			kind: EnvBareMetal,
+		},
+		{
+			name: "KVM",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":   "Red Hat\n",
+				"sys/class/dmi/id/product_name": "KVM\n",
+				"sys/class/dmi/id/bios_vendor":  "SeaBIOS\n",
+				"proc/cpuinfo":                  cpuinfoHypervisor,
+			},
+			kind: EnvVM, hypervisor: "kvm", signals: []string{"dmi:kvm", "cpuinfo:hypervisor"},
+		},
+		{
+			name: "QEMU",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":   "QEMU\n",
+				"sys/class/dmi/id/product_name": "Standard PC (Q35 + ICH9, 2009)\n",
+				"proc/cpuinfo":                  cpuinfoHypervisor,
+			},
+			kind: EnvVM, hypervisor: "qemu", signals: []string{"dmi:qemu", "cpuinfo:hypervisor"},
+		},
+		{
+			name: "VMware",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":   "VMware, Inc.\n",
+				"sys/class/dmi/id/product_name": "VMware Virtual Platform\n",
+				"sys/class/dmi/id/bios_vendor":  "Phoenix Technologies LTD\n",
+				"proc/cpuinfo":                  cpuinfoHypervisor,
+			},
+			kind: EnvVM, hypervisor: "vmware", signals: []string{"dmi:vmware"},
+		},
+		{
+			name: "Hyper-V",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":   "Microsoft Corporation\n",
+				"sys/class/dmi/id/product_name": "Virtual Machine\n",
+				"sys/class/dmi/id/board_vendor": "Microsoft Corporation\n",
+				"proc/cpuinfo":                  cpuinfoHypervisor,
+			},
+			kind: EnvVM, hypervisor: "hyperv", signals: []string{"dmi:hyperv"},
+		},
+		{
+			name: "仅 CPU 标记 hypervisor",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor": "Unknown Vendor\n",
+				"proc/cpuinfo":                cpuinfoHypervisor,
+			},
+			kind: EnvVM, signals: []string{"cpuinfo:hypervisor"},
+		},
+		{
+			name: "Docker（cgroup v2）",
+			files: map[string]string{
+				".dockerenv":    "",
+				"proc/1/cgroup": "0::/\n",
+				"proc/cpuinfo":  cpuinfoBareMetal,
+			},
+			kind: EnvContainer, container: "docker", signals: []string{"file:/.dockerenv"},
+		},
+		{
+			name: "Docker（cgroup v1）",
+			files: map[string]string{
+				"proc/1/cgroup": "12:pids:/docker/3f5c2a9e0d1b\n11:memory:/docker/3f5c2a9e0d1b\n0::/system.slice/containerd.service\n",
+			},
+			kind: EnvContainer, container: "docker", signals: []string{"cgroup:docker"},
+		},
+		{
+			name: "Podman",
+			files: map[string]string{
+				"run/.containerenv": "engine=\"podman-4.9.3\"\n",
+				"proc/1/cgroup":     "0::/\n",
+			},
+			kind: EnvContainer, container: "podman", signals: []string{"file:/run/.containerenv"},
+		},
+		{
+			name: "Podman（cgroup 标记）",
+			files: map[string]string{
+				"proc/1/cgroup": "0::/machine.slice/libpod-9b2e7c1d4a.scope/container\n",
+			},
+			kind: EnvContainer, container: "podman", signals: []string{"cgroup:libpod"},
+		},
+		{
+			name: "Kubernetes（虚拟机节点）",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor": "QEMU\n",
+				"proc/1/cgroup":               "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1a2b.slice/cri-containerd-7c9d.scope\n",
+				"proc/cpuinfo":                cpuinfoHypervisor,
+			},
+			kind: EnvContainer, hypervisor: "qemu", container: "kubernetes", signals: []string{"cgroup:kubepods", "dmi:qemu"},
+		},
+		{
+			name: "WSL2",
+			files: map[string]string{
+				"proc/version":  "Linux version 5.15.153.1-microsoft-standard-WSL2 (root@941d701f84f1) (gcc (GCC) 12.2.0)\n",
+				"proc/1/cgroup": "0::/\n",
+				"proc/cpuinfo":  cpuinfoHypervisor,
+			},
+			kind: EnvWSL, signals: []string{"proc_version:microsoft", "cpuinfo:hypervisor"},
+		},
+		{
+			name: "WSL 中的 Docker",
+			files: map[string]string{
+				"proc/version": "Linux version 5.15.153.1-microsoft-standard-WSL2\n",
+				".dockerenv":   "",
+			},
+			kind: EnvWSL, container: "docker", signals: []string{"proc_version:microsoft", "file:/.dockerenv"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSysTree(t)
+			for rel, data := range tt.files {
+				s.write(rel, data)
+			}
+			env := envProbe{root: s.root}.detect(nil)
+			if env.Kind != tt.kind || env.Hypervisor != tt.hypervisor || env.Container != tt.container {
+				t.Fatalf("期望 %s/%q/%q, 得到 %s/%q/%q (%v)", tt.kind, tt.hypervisor, tt.container, env.Kind, env.Hypervisor, env.Container, env.Signals)
+			}
+			for _, sig := range tt.signals {
+				if !slices.Contains(env.Signals, sig) {
+					t.Fatalf("缺少信号 %q: %v", sig, env.Signals)
+				}
+			}
+			if env.Cloud != nil {
+				t.Fatalf("非云环境不应识别出云厂商: %+v", env.Cloud)
+			}
+		})
+	}
+}
+
+// 非 Linux 平台只依据已采集的厂商/型号槽位判断
+func TestClassifyEnvironmentFromSysInfo(t *testing.T) {
+	tests := []struct {
+		vendor, model string
+		kind          string
+		hypervisor    string
+	}{
+		{"VMWARE,INC.", "VMWARE7,1", EnvVM, "vmware"},
+		{"MICROSOFTCORPORATION", "VIRTUALMACHINE", EnvVM, "hyperv"},
+		{"INNOTEKGMBH", "VIRTUALBOX", EnvVM, "virtualbox"},
+		{"PARALLELSINTERNATIONALGMBH.", "PARALLELSARMVIRTUALMACHINE", EnvVM, "parallels"},
+		{"MACMINI9,1", "MACMINI", EnvBareMetal, ""},
+		{"LENOVO", "20XW004JUS", EnvBareMetal, ""},
+		{SysInfoUnavailable, SysInfoUnavailable, EnvBareMetal, ""},
+	}
+	for _, tt := range tests {
+		env := classifyEnvironment([]string{tt.vendor, tt.model}, nil)
+		if env.Kind != tt.kind || env.Hypervisor != tt.hypervisor {
+			t.Errorf("%s/%s: 期望 %s/%q, 得到 %s/%q", tt.vendor, tt.model, tt.kind, tt.hypervisor, env.Kind, env.Hypervisor)
+		}
+	}
+}
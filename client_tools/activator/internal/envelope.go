@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// * 加密载荷信封
+// * encrypted_script_blob 不再是裸的 nonce || 密文，而是自描述的二进制信封：
+// *   magic "ACEV"(4) | version(1) | algorithm(1) | key_id 长度(1) | key_id | flags(1) | nonce | ciphertext+tag
+// * nonce 长度由算法决定（分段算法为 nonce 前缀）；头部（magic 至 nonce）作为 AAD 的前缀参与认证，任何头部字段被改动都会导致解密失败。
+// * 解析严格：未知版本、算法、标志位或长度不符一律拒绝，不回退为旧的裸 nonce || 密文格式。
+
+const (
+	EnvelopeMagic    = "ACEV"
+	EnvelopeVersion1 = 1
+)
+
+// 信封算法标识
+const (
+	EnvelopeAlgAES256GCM byte = 0x01
+	// EnvelopeAlgAES256GCMStream 分段 AEAD（STREAM 构造），见 stream_aead.go
+	EnvelopeAlgAES256GCMStream byte = 0x02
+)
+
+// 信封标志位
+const (
+	// EnvelopeFlagGzip 明文在加密前经 gzip 压缩；解压窗口无法锁定或擦除，敏感脚本不应使用（见 OpenScriptPayload）
+	EnvelopeFlagGzip byte = 1 << 0
+
+	envelopeKnownFlags = EnvelopeFlagGzip
+)
+
+// EnvelopeKeyIDSessionScriptKey 以会话脚本密钥（SSK）加密的载荷
+const EnvelopeKeyIDSessionScriptKey = "ssk"
+
+const (
+	// MaxEnvelopeKeyIDSize key_id 最大长度
+	MaxEnvelopeKeyIDSize = 64
+	// MaxDecompressedScriptSize 脚本明文（解压后）上限：脚本须整体读入内存校验后才执行，同时防止压缩炸弹
+	MaxDecompressedScriptSize = 64 << 20
+)
+
+// ErrInvalidEnvelope 信封格式无效
+var ErrInvalidEnvelope = errors.New("加密载荷格式无效")
+
+// Envelope 解析后的信封
+type Envelope struct {
+	Version    byte
+	Algorithm  byte
+	KeyID      string
+	Flags      byte
+	Nonce      []byte
+	Ciphertext []byte // 含认证标签
+}
+
+// envelopeAlgorithm 算法参数
+type envelopeAlgorithm struct {
+	nonceSize int
+	tagSize   int
+}
+
+var envelopeAlgorithms = map[byte]envelopeAlgorithm{
+	EnvelopeAlgAES256GCM:       {nonceSize: 12, tagSize: 16},
+	EnvelopeAlgAES256GCMStream: {nonceSize: streamNoncePrefixSize, tagSize: 16},
+}
+
+func envelopeErr(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidEnvelope, fmt.Sprintf(format, args...))
+}
+
+// ParseEnvelope 严格解析信封；返回的切片引用 b
+func ParseEnvelope(b []byte) (*Envelope, error) {
+	e, n, err := parseEnvelopeHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < n+envelopeAlgorithms[e.Algorithm].tagSize {
+		return nil, envelopeErr("密文被截断")
+	}
+	e.Ciphertext = b[n:]
+	return e, nil
+}
+
+// ReadEnvelopeHeader 从 r 逐段读取并严格解析信封头部，之后 r 位于密文起始处；返回的 Ciphertext 为 nil
+func ReadEnvelopeHeader(r io.Reader) (*Envelope, error) {
+	const fixed = len(EnvelopeMagic) + 3
+	hdr := make([]byte, fixed, fixed+MaxEnvelopeKeyIDSize+1+16)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, envelopeErr("头部被截断")
+	}
+	if _, _, err := parseEnvelopeHeader(hdr); !errors.Is(err, errEnvelopeShort) {
+		return nil, err
+	}
+	// magic、版本与算法已校验，继续读取 key_id、flags 与 nonce
+	rest := int(hdr[fixed-1]) + 1 + envelopeAlgorithms[hdr[fixed-2]].nonceSize
+	hdr = append(hdr, make([]byte, rest)...)
+	if _, err := io.ReadFull(r, hdr[fixed:]); err != nil {
+		return nil, envelopeErr("头部被截断")
+	}
+	e, _, err := parseEnvelopeHeader(hdr)
+	return e, err
+}
+
+// errEnvelopeShort 头部不完整（ReadEnvelopeHeader 借此判断需要继续读取）
+var errEnvelopeShort = fmt.Errorf("%w: 头部被截断", ErrInvalidEnvelope)
+
+// parseEnvelopeHeader 解析头部，返回头部长度
+func parseEnvelopeHeader(b []byte) (*Envelope, int, error) {
+	if len(b) < len(EnvelopeMagic)+3 || string(b[:len(EnvelopeMagic)]) != EnvelopeMagic {
+		return nil, 0, envelopeErr("magic 不匹配")
+	}
+	p := len(EnvelopeMagic)
+	e := &Envelope{Version: b[p], Algorithm: b[p+1]}
+	if e.Version != EnvelopeVersion1 {
+		return nil, 0, envelopeErr("不支持的版本 %d", e.Version)
+	}
+	alg, ok := envelopeAlgorithms[e.Algorithm]
+	if !ok {
+		return nil, 0, envelopeErr("不支持的算法 0x%02x", e.Algorithm)
+	}
+	keyIDLen := int(b[p+2])
+	p += 3
+	if keyIDLen == 0 || keyIDLen > MaxEnvelopeKeyIDSize {
+		return nil, 0, envelopeErr("key_id 长度无效 %d", keyIDLen)
+	}
+	if len(b) < p+keyIDLen+1 {
+		return nil, 0, errEnvelopeShort
+	}
+	keyID := b[p : p+keyIDLen]
+	for _, c := range keyID {
+		if c < 0x21 || c > 0x7e {
+			return nil, 0, envelopeErr("key_id 含非法字符")
+		}
+	}
+	e.KeyID = string(keyID)
+	p += keyIDLen
+	e.Flags = b[p]
+	p++
+	if e.Flags&^envelopeKnownFlags != 0 {
+		return nil, 0, envelopeErr("未知标志位 0x%02x", e.Flags&^envelopeKnownFlags)
+	}
+	if len(b) < p+alg.nonceSize {
+		return nil, 0, errEnvelopeShort
+	}
+	e.Nonce = b[p : p+alg.nonceSize]
+	return e, p + alg.nonceSize, nil
+}
+
+// Header 返回头部字节（magic 至 nonce），作为 AAD 前缀
+func (e *Envelope) Header() []byte {
+	out := make([]byte, 0, len(EnvelopeMagic)+4+len(e.KeyID)+len(e.Nonce))
+	out = append(out, EnvelopeMagic...)
+	out = append(out, e.Version, e.Algorithm, byte(len(e.KeyID)))
+	out = append(out, e.KeyID...)
+	out = append(out, e.Flags)
+	return append(out, e.Nonce...)
+}
+
+// MarshalBinary 编码信封；对字段做与 ParseEnvelope 相同的校验
+func (e *Envelope) MarshalBinary() ([]byte, error) {
+	out := append(e.Header(), e.Ciphertext...)
+	if _, err := ParseEnvelope(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AAD 返回信封头部与上层 AAD 的拼接
+func (e *Envelope) AAD(aad []byte) []byte {
+	return append(e.Header(), aad...)
+}
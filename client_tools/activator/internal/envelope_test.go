@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// FuzzParseEnvelope 以合法信封（整体 / 分段、压缩 / 不压缩）为种子变异，检查：
+//   - ParseEnvelope 不发生 panic，失败时返回 ErrInvalidEnvelope
+//   - 解析成功的输入重新编码后与原输入逐字节一致（解析器只接受规范编码）
+//   - 变异后的信封不会被当作原载荷解密
+//
+// go test -run '^$' -fuzz FuzzParseEnvelope -fuzzminimizetime 0 ./internal
+// 分段种子跨越段边界、体积较大，对它的最小化极慢，因此关闭最小化。
+func FuzzParseEnvelope(f *testing.F) {
+	ssk := bytes.Repeat([]byte{0x5a}, 32)
+	aad := []byte("envelope-fuzz")
+	script := []byte("echo configured\n")
+	// nonce 取固定值：模糊测试的工作进程会重新执行这里，种子须与协调进程下发的语料一致
+	nonce := func(b byte) *bytes.Reader { return bytes.NewReader(bytes.Repeat([]byte{b}, 16)) }
+	var seeds [][]byte
+	for i, compress := range []bool{false, true} {
+		seeds = append(seeds,
+			encryptScriptBlob(f, nonce(byte(2*i)), ssk, script, aad, compress),
+			// 分段算法：明文跨越段边界
+			encryptStreamBlob(f, nonce(byte(2*i+1)), ssk, bytes.Repeat(script, StreamSegmentSize/len(script)+3), aad, compress),
+		)
+	}
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, in []byte) {
+		e, err := ParseEnvelope(in)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidEnvelope) {
+				t.Fatalf("解析失败应返回 ErrInvalidEnvelope: %v", err)
+			}
+			return
+		}
+		out, err := e.MarshalBinary()
+		if err != nil || !bytes.Equal(out, in) {
+			t.Fatalf("重新编码不一致 (%v)\n输入: %x\n输出: %x", err, in, out)
+		}
+		if _, err := openScriptBlob(ssk, in, aad); err == nil {
+			for _, s := range seeds {
+				if bytes.Equal(in, s) {
+					return
+				}
+			}
+			t.Fatalf("变异后的信封解密成功\n输入: %x", in)
+		}
+	})
+}
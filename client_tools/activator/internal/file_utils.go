@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sync"
 )
 
 // 保存字节数据到文件
@@ -63,8 +64,12 @@ func JoinPath(elem ...string) string {
 	return filepath.Join(elem...)
 }
 
-// 日志写入到log.txt，避免与test.txt混淆
+var testLogMu sync.Mutex
+
+// 日志写入到log.txt，避免与test.txt混淆（并发采集时串行写入）
 func AppendTestLog(msg string) {
+	testLogMu.Lock()
+	defer testLogMu.Unlock()
 	f, _ := os.OpenFile("log.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	defer f.Close()
 	f.WriteString(msg + "\n")
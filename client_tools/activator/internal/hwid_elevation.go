@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// * 提权策略
+// * auto：    需要时启动特权辅助进程，失败则以非特权结果继续（默认，与原行为一致）
+// * never：   从不提权，权限不足的槽位改用回退来源，以部分指纹继续，并逐槽位说明原因（适用于 CI、kiosk 等无人值守场景）
+// * require： 需要提权却无法完成时立即失败，不以部分指纹继续
+// * Linux 上 product_uuid、board_serial、/sys/firmware/dmi/tables 仅 root 可读，读取被拒即视为需要提权；
+// * Linux 上提权方式可为 sudo 或 pkexec，由 ElevatorEnv 指定，未指定时优先 sudo。
+
+// ElevationPolicy 提权策略
+type ElevationPolicy string
+
+const (
+	ElevationAuto    ElevationPolicy = "auto"
+	ElevationNever   ElevationPolicy = "never"
+	ElevationRequire ElevationPolicy = "require"
+)
+
+// ElevationPolicyEnv 未通过参数指定策略时读取的环境变量
+const ElevationPolicyEnv = "ACTIVATOR_ELEVATION"
+
+// ElevatorEnv 指定 Linux 提权方式（sudo / pkexec）的环境变量
+const ElevatorEnv = "ACTIVATOR_ELEVATOR"
+
+// ParseElevationPolicy 解析策略名称，空字符串视为 auto
+func ParseElevationPolicy(s string) (ElevationPolicy, error) {
+	switch p := ElevationPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return ElevationAuto, nil
+	case ElevationAuto, ElevationNever, ElevationRequire:
+		return p, nil
+	default:
+		return "", fmt.Errorf("无效的提权策略 %q（可选 auto、never、require）", s)
+	}
+}
+
+// ElevationOutcome 提权结果
+type ElevationOutcome string
+
+const (
+	ElevationNotNeeded       ElevationOutcome = "not_needed"       // 所有槽位无需提权
+	ElevationAlreadyElevated ElevationOutcome = "already_elevated" // 当前进程已具备特权
+	ElevationSucceeded       ElevationOutcome = "elevated"         // 特权辅助进程采集成功
+	ElevationSkipped         ElevationOutcome = "skipped"          // never 策略下未提权
+	ElevationFailed          ElevationOutcome = "failed"           // 提权失败，使用非特权结果
+)
+
+// ElevationSlotStatus 因未提权而缺失的槽位及原因
+type ElevationSlotStatus struct {
+	Slot   int    `json:"slot"`
+	Reason string `json:"reason"`
+}
+
+// ElevationReport 随激活请求上报的提权策略与结果
+type ElevationReport struct {
+	Mode    ElevationPolicy       `json:"mode"`
+	Outcome ElevationOutcome      `json:"outcome"`
+	Method  string                `json:"method,omitempty"`
+	Error   string                `json:"error,omitempty"`
+	Slots   []ElevationSlotStatus `json:"slots,omitempty"`
+}
+
+// ElevationRequiredError require 策略下无法完成提权
+type ElevationRequiredError struct {
+	Slots []ElevationSlotStatus
+	Err   error
+}
+
+func (e *ElevationRequiredError) Error() string {
+	names := make([]string, len(e.Slots))
+	for i, s := range e.Slots {
+		names[i] = slotName(s.Slot)
+	}
+	return fmt.Sprintf("提权策略为 require，但无法以特权采集 %s: %v", strings.Join(names, ", "), e.Err)
+}
+
+func (e *ElevationRequiredError) Unwrap() error { return e.Err }
+
+// elevationFlow 提权流程依赖的采集与提权操作，测试中可替换
+type elevationFlow struct {
+	collect         func(ctx context.Context) []SlotResult
+	elevated        func() bool
+	method          func() (string, error)
+	collectElevated func(ctx context.Context, method string) ([]SlotResult, error)
+}
+
+var defaultElevationFlow = elevationFlow{
+	collect:         CollectSysInfoReport,
+	elevated:        isElevated,
+	method:          elevationMethod,
+	collectElevated: collectElevated,
+}
+
+// CollectSysInfoWithPolicy 按提权策略采集各槽位；require 策略下提权失败返回 *ElevationRequiredError
+func CollectSysInfoWithPolicy(ctx context.Context, policy ElevationPolicy) ([]SlotResult, ElevationReport, error) {
+	return defaultElevationFlow.run(ctx, policy)
+}
+
+// run 权限不足的槽位在 auto/require 下不取回退来源的值，等待特权采集；
+// 只有 never 策略会改用回退来源，这些槽位仍列入 report.Slots
+func (f elevationFlow) run(ctx context.Context, policy ElevationPolicy) ([]SlotResult, ElevationReport, error) {
+	report := ElevationReport{Mode: policy}
+	if policy == ElevationNever {
+		ctx = withoutElevation(ctx)
+	}
+	results := f.collect(ctx)
+	pending := pendingElevationSlots(results)
+
+	switch {
+	case len(pending) == 0:
+		report.Outcome = ElevationNotNeeded
+		return results, report, nil
+	case f.elevated():
+		report.Outcome = ElevationAlreadyElevated
+		report.Slots = pending
+		return results, report, nil
+	case policy == ElevationNever:
+		report.Outcome = ElevationSkipped
+		report.Slots = pending
+		return results, report, nil
+	}
+
+	// 仅以特权运行采集辅助进程，其余流程继续在当前（非特权）进程中执行
+	method, err := f.method()
+	if err == nil {
+		report.Method = method
+		AppendTestLog("collectElevated called, method: " + method)
+		var elevated []SlotResult
+		if elevated, err = f.collectElevated(ctx, method); err == nil {
+			report.Outcome = ElevationSucceeded
+			report.Slots = pendingElevationSlots(elevated)
+			return elevated, report, nil
+		}
+	}
+	if policy == ElevationRequire {
+		return results, report, &ElevationRequiredError{Slots: pending, Err: err}
+	}
+	AppendTestLog("collectElevated failed, using unprivileged results: " + err.Error())
+	report.Outcome = ElevationFailed
+	report.Error = err.Error()
+	report.Slots = pending
+	return results, report, nil
+}
+
+// pendingElevationSlots 返回仍需提权才能以首选来源取值的槽位，原因取自该槽位的失败记录
+func pendingElevationSlots(results []SlotResult) []ElevationSlotStatus {
+	var out []ElevationSlotStatus
+	for i, r := range results {
+		if !r.NeedElevate {
+			continue
+		}
+		reason := strings.Join(r.Failures, "; ")
+		if reason == "" {
+			reason = ErrElevationRequired.Error()
+		}
+		out = append(out, ElevationSlotStatus{Slot: i, Reason: reason})
+	}
+	return out
+}
+
+// isElevated 当前进程是否已具备特权（特权辅助进程或以 root 运行）
+func isElevated() bool {
+	return os.Getenv("ELEVATE_FLAG") == "1" || os.Geteuid() == 0
+}
+
+// elevationMethod 选择提权方式：Windows 为 runas；Linux 按 ElevatorEnv 或依次尝试 sudo、pkexec；macOS 为 sudo
+func elevationMethod() (string, error) {
+	if runtime.GOOS == "windows" {
+		return "runas", nil
+	}
+	candidates := []string{"sudo"}
+	if runtime.GOOS == "linux" {
+		candidates = append(candidates, "pkexec")
+	}
+	if want := strings.TrimSpace(os.Getenv(ElevatorEnv)); want != "" {
+		valid := false
+		for _, c := range candidates {
+			valid = valid || c == want
+		}
+		if !valid {
+			return "", fmt.Errorf("%s=%s 不受支持（可选 %s）", ElevatorEnv, want, strings.Join(candidates, "、"))
+		}
+		candidates = []string{want}
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c); err == nil {
+			return c, nil
+		}
+	}
+	return "", errors.New("未找到可用的提权工具: " + strings.Join(candidates, "、"))
+}
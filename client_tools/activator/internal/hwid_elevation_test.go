@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+)
+
+func TestPermissionAsElevation(t *testing.T) {
+	denied := &fs.PathError{Op: "open", Path: "/sys/class/dmi/id/product_uuid", Err: syscall.EACCES}
+	if err := permissionAsElevation(denied); !errors.Is(err, ErrElevationRequired) || !strings.Contains(err.Error(), "product_uuid") {
+		t.Fatalf("EACCES 应转为需要提权: %v", err)
+	}
+	if err := permissionAsElevation(&fs.PathError{Op: "open", Path: "/sys/firmware/dmi/tables/DMI", Err: syscall.EPERM}); !errors.Is(err, ErrElevationRequired) {
+		t.Fatalf("EPERM 应转为需要提权: %v", err)
+	}
+	missing := &fs.PathError{Op: "open", Path: "/sys/class/dmi/id/board_serial", Err: syscall.ENOENT}
+	if err := permissionAsElevation(missing); errors.Is(err, ErrElevationRequired) {
+		t.Fatalf("文件不存在不应要求提权: %v", err)
+	}
+	if permissionAsElevation(nil) != nil {
+		t.Fatal("nil 应原样返回")
+	}
+}
+
+// elevationTestFlow 槽位 0 的首选来源（如 product_uuid）读取被拒，回退来源（如设备树）可用；其余槽位正常
+func elevationTestFlow(t *testing.T, elevateErr error) (elevationFlow, *atomic.Int32, *atomic.Int32) {
+	t.Chdir(t.TempDir())
+	var fallbackCalls, helperCalls atomic.Int32
+	r := NewRegistry()
+	r.Register(NewCollector("file: /sys/class/dmi/id/product_uuid", 0, "test", false, func(ctx context.Context) (string, error) {
+		return SysInfoUnavailable, permissionAsElevation(&fs.PathError{Op: "open", Path: "/sys/class/dmi/id/product_uuid", Err: syscall.EACCES})
+	}))
+	r.Register(NewCollector("device-tree: serial-number", 0, "test", false, func(ctx context.Context) (string, error) {
+		fallbackCalls.Add(1)
+		return "FALLBACK01", nil
+	}))
+	for slot := 1; slot < SysInfoSlotCount; slot++ {
+		r.Register(NewCollector("value", slot, "test", false, func(ctx context.Context) (string, error) { return "VALUE0001", nil }))
+	}
+	flow := elevationFlow{
+		collect:  func(ctx context.Context) []SlotResult { return r.Collect(ctx, "test") },
+		elevated: func() bool { return false },
+		method:   func() (string, error) { return "sudo", nil },
+		collectElevated: func(ctx context.Context, method string) ([]SlotResult, error) {
+			helperCalls.Add(1)
+			if elevateErr != nil {
+				return nil, elevateErr
+			}
+			out := make([]SlotResult, SysInfoSlotCount)
+			for i := range out {
+				out[i] = SlotResult{Value: "PRIVILEGED", Source: "helper"}
+			}
+			return out, nil
+		},
+	}
+	return flow, &fallbackCalls, &helperCalls
+}
+
+func TestElevationPolicies(t *testing.T) {
+	sudoMissing := errors.New("未找到可用的提权工具: sudo、pkexec")
+	tests := []struct {
+		name       string
+		policy     ElevationPolicy
+		elevateErr error
+		outcome    ElevationOutcome
+		slot0      string
+		fallback   int32
+		helper     int32
+		pending    []int
+		wantErr    bool
+	}{
+		{name: "auto 提权成功", policy: ElevationAuto, outcome: ElevationSucceeded, slot0: "PRIVILEGED", helper: 1},
+		{name: "auto 提权失败时不取回退来源", policy: ElevationAuto, elevateErr: sudoMissing, outcome: ElevationFailed, slot0: SysInfoUnavailable, helper: 1, pending: []int{0}},
+		{name: "never 改用回退来源", policy: ElevationNever, outcome: ElevationSkipped, slot0: "FALLBACK01", fallback: 1, pending: []int{0}},
+		{name: "require 提权成功", policy: ElevationRequire, outcome: ElevationSucceeded, slot0: "PRIVILEGED", helper: 1},
+		{name: "require 提权失败立即失败", policy: ElevationRequire, elevateErr: sudoMissing, slot0: SysInfoUnavailable, helper: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flow, fallbackCalls, helperCalls := elevationTestFlow(t, tt.elevateErr)
+			results, report, err := flow.run(context.Background(), tt.policy)
+			if tt.wantErr {
+				var reqErr *ElevationRequiredError
+				if !errors.As(err, &reqErr) || len(reqErr.Slots) != 1 || reqErr.Slots[0].Slot != 0 {
+					t.Fatalf("期望 ElevationRequiredError(info1), 得到 %v", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.wantErr && report.Outcome != tt.outcome {
+				t.Fatalf("结果: 期望 %s, 得到 %s", tt.outcome, report.Outcome)
+			}
+			if results[0].Value != tt.slot0 {
+				t.Fatalf("info1: 期望 %q, 得到 %q", tt.slot0, results[0].Value)
+			}
+			if fallbackCalls.Load() != tt.fallback || helperCalls.Load() != tt.helper {
+				t.Fatalf("回退来源调用 %d 次、特权辅助进程 %d 次, 期望 %d、%d", fallbackCalls.Load(), helperCalls.Load(), tt.fallback, tt.helper)
+			}
+			var pending []int
+			for _, s := range report.Slots {
+				pending = append(pending, s.Slot)
+				if !strings.Contains(s.Reason, "permission denied") {
+					t.Fatalf("原因应包含权限错误: %q", s.Reason)
+				}
+			}
+			if !tt.wantErr && !slices.Equal(pending, tt.pending) {
+				t.Fatalf("待提权槽位: 期望 %v, 得到 %v", tt.pending, pending)
+			}
+		})
+	}
+}
+
+func TestElevationAlreadyElevated(t *testing.T) {
+	flow, _, helperCalls := elevationTestFlow(t, nil)
+	flow.elevated = func() bool { return true }
+	_, report, err := flow.run(context.Background(), ElevationRequire)
+	if err != nil || report.Outcome != ElevationAlreadyElevated || helperCalls.Load() != 0 {
+		t.Fatalf("已提权时不应再启动辅助进程: %v %+v", err, report)
+	}
+}
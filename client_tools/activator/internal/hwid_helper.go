@@ -0,0 +1,241 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// * 特权 HWID 辅助进程
+// * 需要提权的探测不再以 sudo/runAs 重新执行整个激活流程，而是只以特权启动本程序的辅助模式：
+// * 辅助进程仅采集硬件槽位，结果经 HMAC 校验后交还给等待中的非特权父进程，
+// * 网络请求与脚本执行始终在非特权父进程中完成。
+// *
+// * 传输方式：
+// *   Linux/macOS：sudo（Linux 亦可 pkexec）继承的 stdin 下发一次性密钥与 nonce，stdout 返回结果
+// *   Windows：    runAs 无法继承句柄，父进程先将密钥与 nonce 写入仅当前用户可读的临时文件，
+// *                辅助进程读取后以结果覆盖该文件；密钥不出现在进程参数中
+
+// HwidHelperArg 辅助模式的隐藏子命令
+const HwidHelperArg = "__hwid-helper"
+
+// HwidHelperTimeout 等待辅助进程（含用户输入密码）的最长时间
+const HwidHelperTimeout = 2 * time.Minute
+
+// helperEnvelope 辅助进程的输出：payload 为 base64 编码的 []SlotResult JSON
+type helperEnvelope struct {
+	Nonce   string `json:"nonce"`
+	Payload string `json:"payload"`
+	MAC     string `json:"mac"`
+}
+
+// helperMAC = HMAC-SHA256(key, nonce || payload)
+func helperMAC(key []byte, nonce, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(nonce))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RunHwidHelper 辅助模式入口（已处于特权状态），返回进程退出码
+func RunHwidHelper(args []string) int {
+	fs := flag.NewFlagSet(HwidHelperArg, flag.ContinueOnError)
+	outPath := fs.String("out", "", "参数与结果文件（Windows）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// 密钥与 nonce 从 stdin（Windows 为参数文件）读取，避免出现在进程参数中
+	var in io.Reader = os.Stdin
+	if *outPath != "" {
+		params, err := os.ReadFile(*outPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "读取辅助进程参数失败:", err)
+			return 2
+		}
+		in = bytes.NewReader(params)
+	}
+	key, nonce, err := readHelperParams(in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultSlotTimeout+5*time.Second)
+	defer cancel()
+	raw, err := json.Marshal(CollectSysInfoReport(ctx))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "序列化采集结果失败:", err)
+		return 1
+	}
+	payload := base64.StdEncoding.EncodeToString(raw)
+	out, _ := json.Marshal(helperEnvelope{Nonce: nonce, Payload: payload, MAC: helperMAC(key, nonce, payload)})
+
+	if *outPath != "" {
+		if err := os.WriteFile(*outPath, out, 0600); err != nil {
+			fmt.Fprintln(os.Stderr, "写入结果失败:", err)
+			return 1
+		}
+		return 0
+	}
+	_, err = os.Stdout.Write(append(out, '\n'))
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// readHelperParams 读取父进程下发的一行参数：十六进制密钥、空格、nonce
+func readHelperParams(r io.Reader) ([]byte, string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && line == "" {
+		return nil, "", fmt.Errorf("读取辅助进程参数失败: %w", err)
+	}
+	keyHex, nonce, _ := strings.Cut(strings.TrimSpace(line), " ")
+	key, err := hex.DecodeString(keyHex)
+	if err != nil || len(key) != 32 || nonce == "" {
+		return nil, "", errors.New("辅助进程参数无效")
+	}
+	return key, nonce, nil
+}
+
+// collectElevated 以 method 指定的方式特权启动辅助进程采集硬件槽位，校验后返回结果
+func collectElevated(ctx context.Context, method string) ([]SlotResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, HwidHelperTimeout)
+	defer cancel()
+
+	key := make([]byte, 32)
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return nil, err
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []byte
+	if runtime.GOOS == "windows" {
+		out, err = runHelperWindows(ctx, exe, hex.EncodeToString(key), nonce)
+	} else {
+		out, err = runHelperUnix(ctx, method, exe, hex.EncodeToString(key), nonce)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return verifyHelperOutput(out, key, nonce)
+}
+
+// verifyHelperOutput 校验 nonce 与 HMAC 并解码采集结果
+func verifyHelperOutput(out, key []byte, nonce string) ([]SlotResult, error) {
+	var env helperEnvelope
+	if err := json.Unmarshal(out, &env); err != nil {
+		return nil, fmt.Errorf("辅助进程输出无效: %w", err)
+	}
+	if env.Nonce != nonce {
+		return nil, errors.New("辅助进程 nonce 不匹配")
+	}
+	if !hmac.Equal([]byte(env.MAC), []byte(helperMAC(key, env.Nonce, env.Payload))) {
+		return nil, errors.New("辅助进程结果完整性校验失败")
+	}
+	raw, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, err
+	}
+	var results []SlotResult
+	if err := json.Unmarshal(raw, &results); err != nil {
+		return nil, err
+	}
+	if len(results) != SysInfoSlotCount {
+		return nil, fmt.Errorf("辅助进程返回的槽位数错误: %d", len(results))
+	}
+	return results, nil
+}
+
+// runHelperUnix 以 sudo -E 或 pkexec 启动辅助进程，经 stdin 下发密钥，从 stdout 读取结果
+func runHelperUnix(ctx context.Context, method, exe, keyHex, nonce string) ([]byte, error) {
+	args := []string{"-E", exe, HwidHelperArg}
+	if method == "pkexec" {
+		// pkexec 不保留环境变量，辅助进程所需参数均经 stdin 传递
+		args = []string{exe, HwidHelperArg}
+	}
+	cmd := exec.CommandContext(ctx, method, args...)
+	cmd.Env = append(os.Environ(), "ELEVATE_FLAG=1")
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	_, _ = io.WriteString(stdin, keyHex+" "+nonce+"\n")
+	stdin.Close()
+	out, readErr := io.ReadAll(io.LimitReader(stdout, 1<<20))
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("特权辅助进程失败: %w", err)
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	return out, nil
+}
+
+// runHelperWindows 以 runAs 启动辅助进程：密钥与 nonce 写入临时文件，等待辅助进程以结果覆盖
+func runHelperWindows(ctx context.Context, exe, keyHex, nonce string) ([]byte, error) {
+	f, err := os.CreateTemp("", "activator-hwid-*.json")
+	if err != nil {
+		return nil, err
+	}
+	outPath := f.Name()
+	defer os.Remove(outPath)
+	_, err = io.WriteString(f, keyHex+" "+nonce+"\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "powershell", helperWindowsArgs(exe, outPath)...)
+	cmd.Env = append(os.Environ(), "ELEVATE_FLAG=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("特权辅助进程失败: %v, output: %s", err, string(out))
+	}
+	return os.ReadFile(outPath)
+}
+
+// helperWindowsArgs Start-Process 的 PowerShell 参数；路径可能含单引号，按单引号字符串转义。
+// -ArgumentList 各项以空格拼接成命令行且不加引号，临时文件路径（可能含空格）另以双引号包裹
+func helperWindowsArgs(exe, outPath string) []string {
+	argList := strings.Join([]string{psQuote(HwidHelperArg), psQuote("--out"), psQuote(`"` + outPath + `"`)}, ",")
+	return []string{"-Command", "Start-Process", "-FilePath", psQuote(exe), "-ArgumentList", argList, "-Verb", "runAs", "-WindowStyle", "Hidden", "-Wait"}
+}
+
+// psQuote PowerShell 单引号字符串：内部的单引号写作两个单引号
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// helperOutput 按辅助进程的格式构造输出
+func helperOutput(t *testing.T, key []byte, nonce string, results []SlotResult) []byte {
+	t.Helper()
+	raw, err := json.Marshal(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := base64.StdEncoding.EncodeToString(raw)
+	out, err := json.Marshal(helperEnvelope{Nonce: nonce, Payload: payload, MAC: helperMAC(key, nonce, payload)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestVerifyHelperOutput(t *testing.T) {
+	key := []byte(strings.Repeat("k", 32))
+	otherKey := []byte(strings.Repeat("x", 32))
+	const nonce, oldNonce = "6e6f6e63652d63757272656e74", "6e6f6e63652d70726576696f7573"
+	results := make([]SlotResult, SysInfoSlotCount)
+	for i := range results {
+		results[i] = SlotResult{Value: "PRIVILEGED", Source: "helper"}
+	}
+	valid := helperOutput(t, key, nonce, results)
+	if got, err := verifyHelperOutput(valid, key, nonce); err != nil || len(got) != SysInfoSlotCount || got[0].Value != "PRIVILEGED" {
+		t.Fatalf("有效输出应通过: %v, %+v", err, got)
+	}
+
+	var env helperEnvelope
+	if err := json.Unmarshal(valid, &env); err != nil {
+		t.Fatal(err)
+	}
+	tamperedPayload := env
+	forged, _ := json.Marshal([]SlotResult{{Value: "FORGED"}, {}, {}, {}, {}})
+	tamperedPayload.Payload = base64.StdEncoding.EncodeToString(forged)
+	tamperedMAC := env
+	tamperedMAC.MAC = strings.Repeat("0", len(env.MAC))
+	truncatedPayload := env
+	truncatedPayload.Payload = env.Payload[:len(env.Payload)/2]
+	marshal := func(e helperEnvelope) []byte {
+		b, _ := json.Marshal(e)
+		return b
+	}
+
+	tests := []struct {
+		name string
+		out  []byte
+		want string
+	}{
+		{"其他密钥计算的 HMAC", helperOutput(t, otherKey, nonce, results), "完整性校验失败"},
+		{"重放上一次请求的输出", helperOutput(t, key, oldNonce, results), "nonce 不匹配"},
+		{"篡改采集结果", marshal(tamperedPayload), "完整性校验失败"},
+		{"篡改 HMAC", marshal(tamperedMAC), "完整性校验失败"},
+		{"载荷被截断", marshal(truncatedPayload), "完整性校验失败"},
+		{"输出被截断", valid[:len(valid)/2], "输出无效"},
+		{"空输出", nil, "输出无效"},
+		{"槽位数错误", helperOutput(t, key, nonce, results[:3]), "槽位数错误"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifyHelperOutput(tt.out, key, nonce)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("期望错误包含 %q, 得到 %v (%+v)", tt.want, err, got)
+			}
+		})
+	}
+}
+
+func TestReadHelperParams(t *testing.T) {
+	keyHex := strings.Repeat("ab", 32)
+	key, nonce, err := readHelperParams(strings.NewReader(keyHex + " 00112233\n"))
+	if err != nil || len(key) != 32 || key[0] != 0xab || nonce != "00112233" {
+		t.Fatalf("解析参数: %x %q %v", key, nonce, err)
+	}
+	// 父进程写入参数时不带换行同样可读
+	if _, _, err := readHelperParams(strings.NewReader(keyHex + " 00112233")); err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range []string{"", "\n", keyHex + "\n", keyHex[:62] + " 00\n", "zz" + keyHex[2:] + " 00\n"} {
+		if _, _, err := readHelperParams(strings.NewReader(in)); err == nil {
+			t.Fatalf("无效参数应失败: %q", in)
+		}
+	}
+}
+
+func TestHelperWindowsArgsQuoting(t *testing.T) {
+	exe := `C:\Users\O'Brien\Downloads\activator.exe`
+	out := `C:\Users\O'Brien\AppData\Local\Temp\activator-hwid-1.json`
+	args := strings.Join(helperWindowsArgs(exe, out), " ")
+	for _, want := range []string{
+		`-FilePath 'C:\Users\O''Brien\Downloads\activator.exe'`,
+		`-ArgumentList '` + HwidHelperArg + `','--out','"C:\Users\O''Brien\AppData\Local\Temp\activator-hwid-1.json"'`,
+	} {
+		if !strings.Contains(args, want) {
+			t.Fatalf("命令缺少 %s:\n%s", want, args)
+		}
+	}
+	if strings.Contains(args, "--key") || strings.Contains(args, "--nonce") {
+		t.Fatalf("密钥不应出现在命令行中: %s", args)
+	}
+	// 未转义的单引号会提前结束字符串，其后的内容被当作 PowerShell 代码执行
+	injected := helperWindowsArgs(`C:\x'; Remove-Item C:\ -Recurse; '.exe`, out)
+	if got := injected[3]; got != `'C:\x''; Remove-Item C:\ -Recurse; ''.exe'` {
+		t.Fatalf("转义结果: %s", got)
+	}
+}
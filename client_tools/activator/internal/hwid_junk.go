@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// * 占位/垃圾值识别
+// * 大量主板出厂未写入真实标识，返回 "To Be Filled By O.E.M."、全 0 / 全 F UUID 等，
+// * 若作为身份绑定会导致成千上万台同型号机器共享同一指纹，此类值一律视为不可用。
+
+// SysInfoBlocklistVersion 黑名单与启发式规则版本，规则变化时递增并随请求上报
+const SysInfoBlocklistVersion = "1"
+
+// ErrJunkValue 采集到的值为占位/垃圾值
+var ErrJunkValue = errors.New("占位或无效值")
+
+// sysInfoBlocklist 已知占位值（原始写法，初始化时按 getAndNormalizeInfo 规则规范化）
+var sysInfoBlocklist = []string{
+	"To Be Filled By O.E.M.",
+	"To be filled by O.E.M.",
+	"Default string",
+	"Default",
+	"System Serial Number",
+	"System Product Name",
+	"System manufacturer",
+	"System Version",
+	"System Name",
+	"Base Board Serial Number",
+	"Base Board Product Name",
+	"Board Serial Number",
+	"Chassis Serial Number",
+	"Serial Number",
+	"SerialNumber",
+	"Type2 - Board Serial Number",
+	"Not Specified",
+	"Not Applicable",
+	"Not Available",
+	"Not Present",
+	"Not Settable",
+	"<BAD INDEX>",
+	"None",
+	"Null",
+	"N/A",
+	"NA",
+	"Unknown",
+	"Undefined",
+	"Invalid",
+	"Empty",
+	"OEM",
+	"O.E.M.",
+	"123456789",
+	"1234567890",
+	"0123456789",
+	"03000200-0400-0500-0006-000700080009",
+}
+
+// sysInfoBlocklistSubstrings 只要包含即视为占位值的片段（已规范化）
+var sysInfoBlocklistSubstrings = []string{
+	"TOBEFILLEDBY",
+	"DEFAULTSTRING",
+}
+
+var normalizedBlocklist = func() map[string]bool {
+	m := make(map[string]bool, len(sysInfoBlocklist))
+	for _, v := range sysInfoBlocklist {
+		v = strings.ToUpper(strings.TrimSpace(v))
+		v = strings.NewReplacer("-", "", ":", "", " ", "").Replace(v)
+		m[v] = true
+	}
+	return m
+}()
+
+// junkReason 判断规范化后的值是否为占位/垃圾值，是则返回原因，否则返回空串
+func junkReason(v string) string {
+	for _, c := range v {
+		if c < 0x20 || c == 0x7f {
+			return "含控制字符"
+		}
+	}
+	if normalizedBlocklist[v] {
+		return "命中占位值黑名单 v" + SysInfoBlocklistVersion
+	}
+	for _, sub := range sysInfoBlocklistSubstrings {
+		if strings.Contains(v, sub) {
+			return "命中占位值黑名单 v" + SysInfoBlocklistVersion
+		}
+	}
+	if len(v) >= 6 && repeatingUnit(v, 4) {
+		return "重复模式"
+	}
+	if len(v) >= 6 && isSequential(v) {
+		return "连续序列"
+	}
+	if len(v) >= 12 && shannonEntropy(v) < 1.0 {
+		return "熵过低"
+	}
+	return ""
+}
+
+// repeatingUnit 值是否由不超过 maxPeriod 个字符的片段重复构成（如 0000、FEFEFE）
+func repeatingUnit(v string, maxPeriod int) bool {
+	for p := 1; p <= maxPeriod && 2*p <= len(v); p++ {
+		ok := true
+		for i := p; i < len(v); i++ {
+			if v[i] != v[i-p] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isSequential 值是否为逐字符递增或递减的序列（如 123456、ABCDEF）
+func isSequential(v string) bool {
+	up, down := true, true
+	for i := 1; i < len(v); i++ {
+		up = up && v[i] == v[i-1]+1
+		down = down && v[i] == v[i-1]-1
+	}
+	return up || down
+}
+
+// shannonEntropy 每字符的香农熵（比特）
+func shannonEntropy(v string) float64 {
+	counts := map[rune]int{}
+	n := 0
+	for _, c := range v {
+		counts[c]++
+		n++
+	}
+	h := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
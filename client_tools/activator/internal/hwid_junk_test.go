@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestJunkValues(t *testing.T) {
+	tests := []struct {
+		raw    string
+		reason string // 空串表示应通过
+	}{
+		// 已知 OEM 占位值
+		{"To Be Filled By O.E.M.", "命中占位值黑名单 v1"},
+		{"To be filled by O.E.M.\n", "命中占位值黑名单 v1"},
+		{"Default string", "命中占位值黑名单 v1"},
+		{"Default String 1", "命中占位值黑名单 v1"},
+		{"System Serial Number", "命中占位值黑名单 v1"},
+		{"Not Specified", "命中占位值黑名单 v1"},
+		{"<BAD INDEX>", "命中占位值黑名单 v1"},
+		{"N/A", "命中占位值黑名单 v1"},
+		{"0123456789", "命中占位值黑名单 v1"},
+		{"03000200-0400-0500-0006-000700080009", "命中占位值黑名单 v1"},
+		{"00000000-0000-0000-0000-000000000000", "重复模式"},
+		{"FFFFFFFF-FFFF-FFFF-FFFF-FFFFFFFFFFFF", "重复模式"},
+		{"ffffffff-ffff-ffff-ffff-ffffffffffff", "重复模式"},
+		{"123412341234", "重复模式"},
+		{"FEFEFEFE", "重复模式"},
+		{"ABCDEF", "连续序列"},
+		{"987654", "连续序列"},
+		{"AAAAAAAABBBBBBBBAAAAAAAA", "熵过低"},
+		{"SN\x01234567", "含控制字符"},
+		// 真实形态的标识应通过
+		{"4C4C4544-0042-3510-8052-B4C04F503432", ""},
+		{"03AC0D8E-1A2B-11EC-8A5C-7C8AE1B2C3D4", ""},
+		{"/7X2BQ42/CN1296358R008D/", ""},
+		{"PF2ABCDE", ""},
+		{"C02XK1ZJJGH5", ""},
+		{"S4EWNX0R123456", ""},
+		{"WD-WCC4N7KJ5XR2", ""},
+		{"Dell Inc.", ""},
+		{"LENOVO", ""},
+		{"QEMU", ""},
+		{"MacBookPro18,3", ""},
+		// 短值不做启发式判断
+		{"AAAAA", ""},
+		{"12345", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			id, err := normalizeInfo(tt.raw, 0)
+			if tt.reason == "" {
+				if err != nil || id == SysInfoUnavailable {
+					t.Fatalf("应通过: %q, %v", id, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrJunkValue) || id != SysInfoUnavailable {
+				t.Fatalf("应判定为占位值: %q, %v", id, err)
+			}
+			if want := ErrJunkValue.Error() + ": " + tt.reason; err.Error() != want {
+				t.Fatalf("原因: 期望 %q, 得到 %q", want, err.Error())
+			}
+		})
+	}
+}
+
+func TestRepeatingUnit(t *testing.T) {
+	tests := []struct {
+		v    string
+		want bool
+	}{
+		{"000000", true},
+		{"ABABAB", true},
+		{"ABCABCABC", true},
+		{"ABCDABCD", true},
+		{"ABCDEABCDE", false}, // 周期 5 超出上限
+		{"ABABA", true},       // 末尾不完整的周期同样视为重复
+		{"ABCDABCDAB", true},
+		{"ABCDAB", false}, // 至少完整重复一次
+		{"ABCDAX", false},
+		{"A", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := repeatingUnit(tt.v, 4); got != tt.want {
+			t.Errorf("repeatingUnit(%q): 期望 %v, 得到 %v", tt.v, tt.want, got)
+		}
+	}
+}
+
+func TestIsSequential(t *testing.T) {
+	tests := []struct {
+		v    string
+		want bool
+	}{
+		{"123456", true},
+		{"654321", true},
+		{"ABCDEFG", true},
+		{"123457", false},
+		{"1234554321", false},
+		{"S4EWNX", false},
+	}
+	for _, tt := range tests {
+		if got := isSequential(tt.v); got != tt.want {
+			t.Errorf("isSequential(%q): 期望 %v, 得到 %v", tt.v, tt.want, got)
+		}
+	}
+}
+
+func TestShannonEntropy(t *testing.T) {
+	tests := []struct {
+		v    string
+		want float64
+	}{
+		{"AAAA", 0},
+		{"ABAB", 1},
+		{"ABCD", 2},
+		{"0123456789ABCDEF", 4},
+		{"AAAB", 0.8112781244591328},
+	}
+	for _, tt := range tests {
+		if got := shannonEntropy(tt.v); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("shannonEntropy(%q): 期望 %v, 得到 %v", tt.v, tt.want, got)
+		}
+	}
+}
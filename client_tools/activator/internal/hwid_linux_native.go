@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// * Linux 原生读取
+// * sysfs/procfs 直接读取，避免每项信息都启动 cat/bash 子进程；
+// * 仅在原生读取失败时才调用 dmidecode、lsblk 等外部工具。
+
+// readSysFile 读取 sysfs/procfs 文件的完整内容（与 cat 输出一致，规范化由调用方完成）
+func readSysFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// execCombined 在 ctx 时限内执行命令并返回合并输出；超时后强制结束，
+// 子进程残留占用输出管道时最多再等待 commandWaitDelay
+func execCombined(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = commandWaitDelay
+	return cmd.CombinedOutput()
+}
+
+// execOutput 同 execCombined，但只返回标准输出（供需要解析的探测使用）
+func execOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = commandWaitDelay
+	return cmd.Output()
+}
+
+const commandWaitDelay = time.Second
+
+// runCmd 直接执行命令并返回合并输出，失败时错误中只附带输出长度
+func runCmd(ctx context.Context, name string, args ...string) (string, error) {
+	out, err := execCombined(ctx, name, args...)
+	if err != nil {
+		return "", fmt.Errorf("%w, output: %s", err, redactOutput(out))
+	}
+	return string(out), nil
+}
+
+// rootMountSource 从 mounts 表中找出挂载到 / 的设备（后挂载者覆盖先挂载者，与 df 行为一致）
+func rootMountSource(mountsPath string) (string, error) {
+	f, err := os.Open(mountsPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	source := ""
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[1] == "/" {
+			source = fields[0]
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(source, "/dev/") {
+		return "", errors.New("根文件系统不在块设备上: " + source)
+	}
+	return source, nil
+}
+
+// lsblkRootDiskSerial 用 lsblk 查询根文件系统所在物理磁盘的序列号（取首行）；
+// 根文件系统直接位于整块磁盘上时 pkname 为空，此时查询该磁盘本身
+func lsblkRootDiskSerial(ctx context.Context) (string, error) {
+	dev, err := rootMountSource("/proc/self/mounts")
+	if err != nil {
+		return "", err
+	}
+	pk, err := runCmd(ctx, "lsblk", "-no", "pkname", dev)
+	if err != nil {
+		return "", err
+	}
+	disk := dev
+	if pk = firstLine(pk); pk != "" {
+		disk = "/dev/" + pk
+	}
+	serial, err := runCmd(ctx, "lsblk", "-ndo", "SERIAL", disk)
+	if err != nil {
+		return "", err
+	}
+	return firstLine(serial), nil
+}
+
+// firstLine 返回去除首尾空白后的第一行
+func firstLine(s string) string {
+	return strings.Split(strings.TrimSpace(s), "\n")[0]
+}
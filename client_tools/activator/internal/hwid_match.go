@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// * 模糊硬件匹配
+// * 整体哈希在更换任一部件后完全改变；改为逐槽位发送带密钥的哈希与权重，
+// * 由服务端按阈值策略（如 "5 项中至少 3 项一致"）判定，失败时返回发生变化的槽位。
+
+// SlotWeights 各槽位默认权重：唯一性标识高，厂商/型号低
+var SlotWeights = [SysInfoSlotCount]int{3, 3, 1, 1, 2}
+
+// HardwareSlotHash 单个槽位的哈希与权重；NonBinding 表示该槽位易变，不参与绑定
+type HardwareSlotHash struct {
+	Slot       int    `json:"slot"`
+	Hash       string `json:"hash"`
+	Weight     int    `json:"weight"`
+	NonBinding bool   `json:"non_binding,omitempty"`
+}
+
+// HardwareMatchPolicy 服务端声明的匹配阈值：匹配槽位数与匹配权重均需达到下限
+type HardwareMatchPolicy struct {
+	MinSlots  int `json:"min_slots"`
+	MinWeight int `json:"min_weight,omitempty"`
+}
+
+// HardwareMatchResult 匹配结果；槽位以 0 起始序号表示
+type HardwareMatchResult struct {
+	Policy        HardwareMatchPolicy `json:"policy"`
+	MatchedSlots  []int               `json:"matched_slots"`
+	ChangedSlots  []int               `json:"changed_slots"`
+	MatchedWeight int                 `json:"matched_weight"`
+	Passed        bool                `json:"passed"`
+}
+
+// ComputeSlotHashes 计算各槽位的 HMAC-SHA256；不可用的槽位权重为 0，不参与匹配
+func ComputeSlotHashes(infos []string, key []byte) []HardwareSlotHash {
+	out := make([]HardwareSlotHash, 0, len(infos))
+	for i, v := range infos {
+		mac := hmac.New(sha256.New, key)
+		// 哈希输入带槽位 tag，相同取值出现在不同槽位时不会得到相同哈希
+		mac.Write(appendSysInfoTLV(nil, sysInfoTagSlotBase+byte(i), v))
+		h := HardwareSlotHash{Slot: i, Hash: hex.EncodeToString(mac.Sum(nil))}
+		if v != SysInfoUnavailable && i < len(SlotWeights) {
+			h.Weight = SlotWeights[i]
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// EvaluateHardwareMatch 按策略比较已登记与当前的槽位哈希（服务端判定逻辑的参考实现，客户端据此在本地定位变化的槽位）；
+// 登记时不可用或任一侧标记为不参与绑定的槽位既不算一致也不算变化，当前不可用的槽位视为变化
+func EvaluateHardwareMatch(enrolled, current []HardwareSlotHash, policy HardwareMatchPolicy) HardwareMatchResult {
+	res := HardwareMatchResult{Policy: policy}
+	cur := make(map[int]HardwareSlotHash, len(current))
+	for _, h := range current {
+		cur[h.Slot] = h
+	}
+	for _, e := range enrolled {
+		c, ok := cur[e.Slot]
+		if e.Weight == 0 || e.NonBinding || (ok && c.NonBinding) {
+			// 登记时即不可用、或云厂商规则判定为易变的槽位不计入
+			continue
+		}
+		if ok && c.Weight > 0 && hmac.Equal([]byte(c.Hash), []byte(e.Hash)) {
+			res.MatchedSlots = append(res.MatchedSlots, e.Slot)
+			res.MatchedWeight += e.Weight
+		} else {
+			res.ChangedSlots = append(res.ChangedSlots, e.Slot)
+		}
+	}
+	res.Passed = len(res.MatchedSlots) >= policy.MinSlots && res.MatchedWeight >= policy.MinWeight
+	return res
+}
+
+// HardwareMismatchError 服务端判定硬件匹配未达阈值
+type HardwareMismatchError struct {
+	Message string
+	Result  HardwareMatchResult
+}
+
+func (e *HardwareMismatchError) Error() string {
+	names := make([]string, len(e.Result.ChangedSlots))
+	for i, s := range e.Result.ChangedSlots {
+		names[i] = slotName(s)
+	}
+	return fmt.Sprintf("硬件匹配未通过（%d 项一致，要求至少 %d 项），发生变化的项: %s: %s",
+		len(e.Result.MatchedSlots), e.Result.Policy.MinSlots, strings.Join(names, ", "), e.Message)
+}
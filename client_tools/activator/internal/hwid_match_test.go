@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+var matchBaseline = []string{"UUID0001", "BOARD001", "VENDOR", "MODEL", "DISK0001"}
+
+// withChanges 复制基线并替换指定槽位
+func withChanges(changes map[int]string) []string {
+	out := slices.Clone(matchBaseline)
+	for slot, v := range changes {
+		out[slot] = v
+	}
+	return out
+}
+
+func TestEvaluateHardwareMatch(t *testing.T) {
+	key := []byte("license-salt-0123456789")
+	aws := &CloudInfo{Provider: CloudAWS, NonBindingSlots: cloudNonBindingSlots[CloudAWS]}
+	tests := []struct {
+		name       string
+		enrolled   []string
+		current    []string
+		policy     HardwareMatchPolicy
+		enrollCld  *CloudInfo
+		currentCld *CloudInfo
+		otherKey   bool
+		matched    []int
+		changed    []int
+		weight     int
+		passed     bool
+	}{
+		{
+			name:     "无变化",
+			enrolled: matchBaseline, current: matchBaseline,
+			policy:  HardwareMatchPolicy{MinSlots: 3},
+			matched: []int{0, 1, 2, 3, 4}, weight: 10, passed: true,
+		},
+		{
+			name:     "更换磁盘（1 项变化）",
+			enrolled: matchBaseline, current: withChanges(map[int]string{4: "DISK0002"}),
+			policy:  HardwareMatchPolicy{MinSlots: 3},
+			matched: []int{0, 1, 2, 3}, changed: []int{4}, weight: 8, passed: true,
+		},
+		{
+			name:     "更换主板与磁盘（2 项变化）",
+			enrolled: matchBaseline, current: withChanges(map[int]string{1: "BOARD002", 4: "DISK0002"}),
+			policy:  HardwareMatchPolicy{MinSlots: 3},
+			matched: []int{0, 2, 3}, changed: []int{1, 4}, weight: 5, passed: true,
+		},
+		{
+			name:     "3 项变化，低于 5 选 3",
+			enrolled: matchBaseline, current: withChanges(map[int]string{0: "UUID0002", 1: "BOARD002", 4: "DISK0002"}),
+			policy:  HardwareMatchPolicy{MinSlots: 3},
+			matched: []int{2, 3}, changed: []int{0, 1, 4}, weight: 2, passed: false,
+		},
+		{
+			name:     "登记时不可用的槽位不计入",
+			enrolled: withChanges(map[int]string{1: SysInfoUnavailable}), current: withChanges(map[int]string{1: "BOARD002"}),
+			policy:  HardwareMatchPolicy{MinSlots: 4},
+			matched: []int{0, 2, 3, 4}, weight: 7, passed: true,
+		},
+		{
+			name:     "当前不可用的槽位视为变化",
+			enrolled: matchBaseline, current: withChanges(map[int]string{0: SysInfoUnavailable, 1: SysInfoUnavailable}),
+			policy:  HardwareMatchPolicy{MinSlots: 4},
+			matched: []int{2, 3, 4}, changed: []int{0, 1}, weight: 4, passed: false,
+		},
+		{
+			name:     "权重恰好等于阈值",
+			enrolled: matchBaseline, current: withChanges(map[int]string{0: "UUID0002"}),
+			policy:  HardwareMatchPolicy{MinSlots: 1, MinWeight: 7},
+			matched: []int{1, 2, 3, 4}, changed: []int{0}, weight: 7, passed: true,
+		},
+		{
+			name:     "权重低于阈值 1",
+			enrolled: matchBaseline, current: withChanges(map[int]string{0: "UUID0002", 2: "VENDOR2"}),
+			policy:  HardwareMatchPolicy{MinSlots: 1, MinWeight: 7},
+			matched: []int{1, 3, 4}, changed: []int{0, 2}, weight: 6, passed: false,
+		},
+		{
+			name:     "槽位数达标但权重不足",
+			enrolled: matchBaseline, current: withChanges(map[int]string{0: "UUID0002", 1: "BOARD002"}),
+			policy:  HardwareMatchPolicy{MinSlots: 3, MinWeight: 5},
+			matched: []int{2, 3, 4}, changed: []int{0, 1}, weight: 4, passed: false,
+		},
+		{
+			name:     "云主机：易变的磁盘槽位不参与绑定",
+			enrolled: matchBaseline, current: withChanges(map[int]string{4: "VOL0002"}),
+			enrollCld: aws, currentCld: aws,
+			policy:  HardwareMatchPolicy{MinSlots: 4},
+			matched: []int{0, 1, 2, 3}, weight: 8, passed: true,
+		},
+		{
+			name:     "登记早于云厂商规则：当前侧标记不参与绑定同样不计入",
+			enrolled: matchBaseline, current: withChanges(map[int]string{4: "VOL0002"}),
+			currentCld: aws,
+			policy:     HardwareMatchPolicy{MinSlots: 4},
+			matched:    []int{0, 1, 2, 3}, weight: 8, passed: true,
+		},
+		{
+			name:     "盐值不同时所有槽位均不一致",
+			enrolled: matchBaseline, current: matchBaseline, otherKey: true,
+			policy:  HardwareMatchPolicy{MinSlots: 1},
+			changed: []int{0, 1, 2, 3, 4}, passed: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enrolled := ComputeSlotHashes(tt.enrolled, key)
+			ApplyCloudBindingPolicy(enrolled, tt.enrollCld)
+			currentKey := key
+			if tt.otherKey {
+				currentKey = []byte("another-license-salt")
+			}
+			current := ComputeSlotHashes(tt.current, currentKey)
+			ApplyCloudBindingPolicy(current, tt.currentCld)
+
+			res := EvaluateHardwareMatch(enrolled, current, tt.policy)
+			if !slices.Equal(res.MatchedSlots, tt.matched) || !slices.Equal(res.ChangedSlots, tt.changed) {
+				t.Fatalf("一致 %v 变化 %v, 期望一致 %v 变化 %v", res.MatchedSlots, res.ChangedSlots, tt.matched, tt.changed)
+			}
+			if res.MatchedWeight != tt.weight || res.Passed != tt.passed {
+				t.Fatalf("权重 %d 通过 %v, 期望权重 %d 通过 %v", res.MatchedWeight, res.Passed, tt.weight, tt.passed)
+			}
+		})
+	}
+}
+
+func TestComputeSlotHashesSlotTagged(t *testing.T) {
+	// 相同取值出现在不同槽位时哈希不同
+	h := ComputeSlotHashes([]string{"SAME", "SAME", "X", "Y", SysInfoUnavailable}, []byte("k"))
+	if h[0].Hash == h[1].Hash {
+		t.Fatal("不同槽位的相同取值不应得到相同哈希")
+	}
+	if h[4].Weight != 0 {
+		t.Fatal("不可用槽位权重应为 0")
+	}
+}
+
+func TestHardwareMismatchErrorNamesChangedSlots(t *testing.T) {
+	err := &HardwareMismatchError{Message: "硬件不匹配", Result: HardwareMatchResult{
+		Policy:       HardwareMatchPolicy{MinSlots: 3},
+		MatchedSlots: []int{2, 3},
+		ChangedSlots: []int{0, 1, 4},
+	}}
+	msg := err.Error()
+	for _, want := range []string{"2 项一致", "至少 3 项", "info1, info2, info5"} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("错误信息 %q 缺少 %q", msg, want)
+		}
+	}
+}
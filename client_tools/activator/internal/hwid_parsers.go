@@ -0,0 +1,113 @@
+package internal
+
+import "strings"
+
+// * 探测输出解析
+// * 执行与解析分离：Windows / macOS 探测只负责取回原始输出，
+// * 这里的纯函数用 Go 复现原先 PowerShell 取值与 grep/awk/sed 管道的结果，可在任意平台上测试。
+
+// ParsePowerShellValue 取 PowerShell 输出中的值：跳过 skipHeader 行后的首个非空行；
+// 若不存在这样的行则返回整个输出（与 V1.1 getAndNormalizeInfo 的行为一致）
+func ParsePowerShellValue(out string, skipHeader int) string {
+	if skipHeader <= 0 {
+		return out
+	}
+	lines := strings.Split(out, "\n")
+	if skipHeader > len(lines) {
+		return out
+	}
+	for _, line := range lines[skipHeader:] {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			return line
+		}
+	}
+	return out
+}
+
+// ParseSystemProfilerUUID 等价于 `system_profiler SPHardwareDataType | grep 'Hardware UUID' | awk '{print $3}'`
+func ParseSystemProfilerUUID(out string) string {
+	return awkPrintField(grepLines(out, "Hardware UUID"), 3)
+}
+
+// ParseSystemProfilerSerial 等价于 `... | grep 'Serial Number (system)' | awk '{print $4}'`
+func ParseSystemProfilerSerial(out string) string {
+	return awkPrintField(grepLines(out, "Serial Number (system)"), 4)
+}
+
+// ParseSystemProfilerModelIdentifier 等价于 `... | grep 'Model Identifier' | awk '{print $3}'`
+func ParseSystemProfilerModelIdentifier(out string) string {
+	return awkPrintField(grepLines(out, "Model Identifier"), 3)
+}
+
+// ParseSystemProfilerModelName 等价于 `... | grep 'Model Name' | sed 's/.*: //'`
+func ParseSystemProfilerModelName(out string) string {
+	var sb strings.Builder
+	for _, line := range grepLines(out, "Model Name") {
+		if i := strings.LastIndex(line, ": "); i >= 0 {
+			line = line[i+2:]
+		}
+		sb.WriteString(line + "\n")
+	}
+	return sb.String()
+}
+
+// ParseDiskSerial 等价于 `... | grep "Serial Number" | awk -F': ' '{print $2}'`；
+// firstOnly 对应管道末尾的 `| head -n 1`
+func ParseDiskSerial(out string, firstOnly bool) string {
+	lines := grepLines(out, "Serial Number")
+	if firstOnly && len(lines) > 1 {
+		lines = lines[:1]
+	}
+	var sb strings.Builder
+	for _, line := range lines {
+		fields := strings.Split(line, ": ")
+		if len(fields) >= 2 {
+			sb.WriteString(fields[1])
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// ParseIORegProperty 取 `ioreg -rd1 -c IOPlatformExpertDevice` 输出中 "key" = 的值：
+// 字符串形式 "..." 与数据形式 <"..."> 均去掉包裹；不存在时返回空串
+func ParseIORegProperty(out, key string) string {
+	prefix := `"` + key + `" = `
+	for _, line := range strings.Split(out, "\n") {
+		_, v, ok := strings.Cut(line, prefix)
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		if strings.HasPrefix(v, "<") && strings.HasSuffix(v, ">") {
+			v = v[1 : len(v)-1]
+		}
+		return strings.Trim(v, `"`)
+	}
+	return ""
+}
+
+// grepLines 返回包含 pattern 的行（固定字符串匹配）
+func grepLines(out, pattern string) []string {
+	var matched []string
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if strings.Contains(line, pattern) {
+			matched = append(matched, line)
+		}
+	}
+	return matched
+}
+
+// awkPrintField 对每行按空白分割并输出第 n 个字段（从 1 开始，缺失时输出空行）
+func awkPrintField(lines []string, n int) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if n <= len(fields) {
+			sb.WriteString(fields[n-1])
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
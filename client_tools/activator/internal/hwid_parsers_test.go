@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testdata/parsers/ 下为各机型的探测输出（PowerShell 样本保留 CRLF），cases.json 为逐文件的期望值：
+// raw 为解析函数的返回值，normalized 为 normalizeInfo 的结果（unavailable 表示占位符，junk 表示判为垃圾值）
+type parserGoldenCase struct {
+	File       string `json:"file"`
+	Parser     string `json:"parser"`
+	Raw        string `json:"raw"`
+	Normalized string `json:"normalized"`
+}
+
+var goldenParsers = map[string]func(string) string{
+	"system_profiler_uuid":             ParseSystemProfilerUUID,
+	"system_profiler_serial":           ParseSystemProfilerSerial,
+	"system_profiler_model_identifier": ParseSystemProfilerModelIdentifier,
+	"system_profiler_model_name":       ParseSystemProfilerModelName,
+	"disk_serial":                      func(out string) string { return ParseDiskSerial(out, false) },
+	"disk_serial_first":                func(out string) string { return ParseDiskSerial(out, true) },
+}
+
+// goldenParser 解析 "powershell:<skipHeader>" 与 "ioreg:<key>" 形式的参数化解析器
+func goldenParser(name string) (parse func(string) string, skipHeader int, ok bool) {
+	if arg, found := strings.CutPrefix(name, "powershell:"); found {
+		n, err := strconv.Atoi(arg)
+		return func(out string) string { return ParsePowerShellValue(out, n) }, n, err == nil
+	}
+	if key, found := strings.CutPrefix(name, "ioreg:"); found {
+		return func(out string) string { return ParseIORegProperty(out, key) }, 0, true
+	}
+	parse, ok = goldenParsers[name]
+	return parse, 0, ok
+}
+
+func TestParsersGolden(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "parsers", "cases.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []parserGoldenCase
+	if err := json.Unmarshal(raw, &cases); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		t.Run(c.File+"/"+c.Parser, func(t *testing.T) {
+			parse, skipHeader, ok := goldenParser(c.Parser)
+			if !ok {
+				t.Fatalf("未知解析器 %q", c.Parser)
+			}
+			out, err := os.ReadFile(filepath.Join("testdata", "parsers", c.File))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := parse(string(out))
+			if got != c.Raw {
+				t.Fatalf("解析结果: 期望 %q, 得到 %q", c.Raw, got)
+			}
+			// 采集器对 PowerShell 输出在 normalizeInfo 中再次按 skipHeader 取值，其余来源已解析
+			norm, err := normalizeInfo(got, skipHeader)
+			switch {
+			case errors.Is(err, ErrJunkValue):
+				norm = "junk"
+			case err != nil:
+				t.Fatal(err)
+			case norm == SysInfoUnavailable:
+				norm = "unavailable"
+			}
+			if norm != c.Normalized {
+				t.Fatalf("规范化结果: 期望 %q, 得到 %q", c.Normalized, norm)
+			}
+		})
+	}
+}
+
+func TestGoldenFixturesCovered(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "parsers", "cases.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []parserGoldenCase
+	if err := json.Unmarshal(raw, &cases); err != nil {
+		t.Fatal(err)
+	}
+	covered := map[string]bool{}
+	for _, c := range cases {
+		covered[c.File] = true
+	}
+	files, _ := filepath.Glob(filepath.Join("testdata", "parsers", "*.txt"))
+	for _, f := range files {
+		if !covered[filepath.Base(f)] {
+			t.Errorf("样本 %s 未出现在 cases.json 中", filepath.Base(f))
+		}
+	}
+}
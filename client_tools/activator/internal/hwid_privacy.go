@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// * 硬件标识隐私保护
+// * 原始主板序列号、系统 UUID、磁盘序列号不离开本机：
+// * 请求中只发送以本令牌挑战盐值计算的 HMAC：盐值由服务端为每个令牌独立签发，不同令牌的哈希无法关联，
+// * 客户端二进制中也不含可用于离线穷举序列号的密钥；
+// * 服务端按各令牌签发的盐值重新计算，将本次 hardware_slots 与此前登记的逐槽位哈希做阈值比对（见 EvaluateHardwareMatch）；
+// * 本地日志与错误信息中只记录长度，不记录原值或命令输出。
+
+// MinChallengeSaltSize 挑战盐值最小长度（字节）
+const MinChallengeSaltSize = 16
+
+// HardwareIDSalt 槽位哈希所用的盐值，即服务端为本令牌签发的挑战盐值；
+// 旧服务端不下发挑战盐值，改用本地随机盐值，仅满足旧接口的必填字段，服务端无法据此比对
+func (c *ActivationChallenge) HardwareIDSalt() ([]byte, error) {
+	if !c.Legacy {
+		return c.Salt, nil
+	}
+	salt := make([]byte, DerivedKeySize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// BlindHardwareIDs 将各槽位值替换为 HMAC-SHA256(salt, 槽位 tag || 值) 的十六进制；
+// 不可用槽位保留占位符，便于服务端识别，且不泄露任何标识
+func BlindHardwareIDs(infos []string, salt []byte) []string {
+	out := make([]string, len(infos))
+	for i, h := range ComputeSlotHashes(infos, salt) {
+		if infos[i] == SysInfoUnavailable {
+			out[i] = SysInfoUnavailable
+			continue
+		}
+		out[i] = h.Hash
+	}
+	return out
+}
+
+// redactInfo 日志用脱敏表示，只保留长度
+func redactInfo(v string) string {
+	return fmt.Sprintf("<redacted len=%d>", len(v))
+}
+
+// redactOutput 命令输出可能含序列号，错误信息中只保留长度
+func redactOutput(out []byte) string {
+	return redactInfo(string(out))
+}
+
+// MaskSysInfo 界面展示用掩码：仅保留首尾各两个字符
+func MaskSysInfo(v string) string {
+	if v == SysInfoUnavailable || len(v) <= 4 {
+		return v
+	}
+	return v[:2] + strings.Repeat("*", len(v)-4) + v[len(v)-2:]
+}
+
+// identifierPattern 可能是序列号的片段：连续 8 个以上字母数字
+var identifierPattern = regexp.MustCompile(`[A-Za-z0-9]{8,}`)
+
+// MaskFailure 界面展示用：失败原因中同时含字母与数字的长片段按 MaskSysInfo 掩码
+func MaskFailure(s string) string {
+	return identifierPattern.ReplaceAllStringFunc(s, func(tok string) string {
+		if strings.IndexFunc(tok, unicode.IsDigit) < 0 || strings.IndexFunc(tok, unicode.IsLetter) < 0 {
+			return tok
+		}
+		return MaskSysInfo(tok)
+	})
+}
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHardwareIDSaltPerToken(t *testing.T) {
+	infos := []string{"UUID0001", "BOARD001", "VENDOR", "MODEL", SysInfoUnavailable}
+	tokenA := &ActivationChallenge{Salt: bytes.Repeat([]byte{1}, MinChallengeSaltSize)}
+	tokenB := &ActivationChallenge{Salt: bytes.Repeat([]byte{2}, MinChallengeSaltSize)}
+	a, err := tokenA.HardwareIDSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := tokenA.HardwareIDSalt()
+	b, _ := tokenB.HardwareIDSalt()
+	if !bytes.Equal(a, tokenA.Salt) || !bytes.Equal(a, again) {
+		t.Fatal("应直接使用本令牌的挑战盐值")
+	}
+	// 同一令牌（重试）的槽位哈希一致，不同令牌之间不可关联
+	if !slices.Equal(BlindHardwareIDs(infos, a), BlindHardwareIDs(infos, again)) {
+		t.Fatal("同一令牌两次计算的硬件 ID 应一致")
+	}
+	ida, idb := BlindHardwareIDs(infos, a), BlindHardwareIDs(infos, b)
+	for i := range 4 {
+		if ida[i] == idb[i] || strings.Contains(ida[i], infos[i]) {
+			t.Fatalf("槽位 %d 可被关联或泄露原值: %q", i, ida[i])
+		}
+	}
+	if ida[4] != SysInfoUnavailable {
+		t.Fatalf("不可用槽位应保留占位符: %q", ida[4])
+	}
+
+	// 旧服务端无挑战盐值：每次使用新的本地随机盐值
+	legacy := &ActivationChallenge{Legacy: true}
+	l1, err := legacy.HardwareIDSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l2, _ := legacy.HardwareIDSalt()
+	if len(l1) != DerivedKeySize || bytes.Equal(l1, l2) {
+		t.Fatalf("旧服务端应使用随机盐值: %x, %x", l1, l2)
+	}
+}
+
+func TestRedactOutputHidesSerials(t *testing.T) {
+	got := redactOutput([]byte("SerialNumber\r\nPF2ABCDE\r\n"))
+	if strings.Contains(got, "PF2ABCDE") || got != "<redacted len=24>" {
+		t.Fatalf("命令输出未脱敏: %q", got)
+	}
+}
+
+func TestMaskFailure(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"/sys/class/dmi/id/board_serial: permission denied", "/sys/class/dmi/id/board_serial: permission denied"},
+		{"sys/block/nvme0n1: 无序列号或 WWID", "sys/block/nvme0n1: 无序列号或 WWID"},
+		{"占位值 PF2ABCDE 无效", "占位值 PF****DE 无效"},
+		{"uuid 4C4C4544-0042-3510", "uuid 4C****44-0042-3510"},
+		{"exit status 1, output: <redacted len=24>", "exit status 1, output: <redacted len=24>"},
+	}
+	for _, tt := range tests {
+		if got := MaskFailure(tt.in); got != tt.want {
+			t.Errorf("MaskFailure(%q) = %q, 期望 %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRequestActivationChallenge(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	salt := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, MinChallengeSaltSize))
+	nonce := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{9}, MinServerNonceSize))
+	tests := []struct {
+		name    string
+		minimum string
+		status  int
+		body    any
+		// signed 为服务端签名的挑战响应；tamper 模拟签名之后的中间人改写，offered 覆盖服务端收到的声明
+		signed    *ActivationChallengeResponse
+		offered   []string
+		tamper    func(r *ActivationChallengeResponse)
+		want      ActivationChallenge
+		wantErr   string
+		advertise []string
+	}{
+		{
+			name:      "协商混合算法",
+			minimum:   DefaultMinKeyAgreement,
+			signed:    &ActivationChallengeResponse{ChallengeSalt: salt, ServerNonce: nonce, KeyAgreement: KeyAgreementHybrid},
+			want:      ActivationChallenge{KeyAgreement: KeyAgreementHybrid},
+			advertise: []string{KeyAgreementHybrid, KeyAgreementX25519},
+		},
+		{
+			name:      "旧服务端无挑战接口，最低算法允许 RSA",
+			minimum:   KeyAgreementRSAOAEP,
+			status:    http.StatusNotFound,
+			body:      map[string]string{"error": "not found"},
+			want:      ActivationChallenge{KeyAgreement: KeyAgreementRSAOAEP, Legacy: true},
+			advertise: SupportedKeyAgreements,
+		},
+		{
+			name:    "旧服务端无挑战接口，默认最低算法拒绝退回 RSA",
+			minimum: DefaultMinKeyAgreement,
+			status:  http.StatusNotFound,
+			body:    map[string]string{"error": "not found"},
+			wantErr: "拒绝降级",
+		},
+		{
+			name:    "最低算法允许 RSA 时删去 key_agreement 使签名失效",
+			minimum: KeyAgreementRSAOAEP,
+			signed:  &ActivationChallengeResponse{ChallengeSalt: salt, ServerNonce: nonce, KeyAgreement: KeyAgreementHybrid},
+			tamper:  func(r *ActivationChallengeResponse) { r.KeyAgreement = "" },
+			wantErr: "签名验证失败",
+		},
+		{
+			name:    "请求中的算法声明被删改",
+			minimum: KeyAgreementRSAOAEP,
+			signed:  &ActivationChallengeResponse{ChallengeSalt: salt, ServerNonce: nonce, KeyAgreement: KeyAgreementRSAOAEP},
+			offered: []string{KeyAgreementRSAOAEP},
+			wantErr: "签名验证失败",
+		},
+		{
+			name:    "挑战未签名",
+			minimum: DefaultMinKeyAgreement,
+			status:  http.StatusOK,
+			body:    ActivationChallengeResponse{ChallengeSalt: salt, ServerNonce: nonce, KeyAgreement: KeyAgreementHybrid},
+			wantErr: "未签名",
+		},
+		{
+			name:    "其他错误仍失败",
+			minimum: DefaultMinKeyAgreement,
+			status:  http.StatusForbidden,
+			body:    map[string]string{"error": "IST已用"},
+			wantErr: "IST已用",
+		},
+		{
+			name:    "挑战盐值过短",
+			minimum: DefaultMinKeyAgreement,
+			signed:  &ActivationChallengeResponse{ChallengeSalt: base64.StdEncoding.EncodeToString([]byte("short")), ServerNonce: nonce, KeyAgreement: KeyAgreementX25519},
+			wantErr: "过短",
+		},
+		{
+			name:    "缺少服务端随机数",
+			minimum: DefaultMinKeyAgreement,
+			signed:  &ActivationChallengeResponse{ChallengeSalt: salt, KeyAgreement: KeyAgreementX25519},
+			wantErr: "随机数过短",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var advertised []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/activation-challenge" {
+					http.NotFound(w, r)
+					return
+				}
+				var req ActivationChallengeRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				advertised = req.KeyAgreements
+				if tt.signed == nil {
+					w.WriteHeader(tt.status)
+					json.NewEncoder(w).Encode(tt.body)
+					return
+				}
+				resp := *tt.signed
+				offered := req.KeyAgreements
+				if tt.offered != nil {
+					offered = tt.offered
+				}
+				resp.Signature = SignActivationChallenge(priv, &resp, req.InstallSessionToken, offered)
+				if tt.tamper != nil {
+					tt.tamper(&resp)
+				}
+				json.NewEncoder(w).Encode(resp)
+			}))
+			defer srv.Close()
+			got, err := RequestActivationChallenge(srv.URL, "token", pub, tt.minimum)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("期望错误包含 %q, 得到 %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.KeyAgreement != tt.want.KeyAgreement || got.Legacy != tt.want.Legacy {
+				t.Fatalf("期望 %+v, 得到 %+v", tt.want, *got)
+			}
+			if !got.Legacy && len(got.ServerNonce) != MinServerNonceSize {
+				t.Fatalf("服务端随机数未解码: %x", got.ServerNonce)
+			}
+			if !slices.Equal(advertised, tt.advertise) {
+				t.Fatalf("声明的算法: 期望 %v, 得到 %v", tt.advertise, advertised)
+			}
+		})
+	}
+}
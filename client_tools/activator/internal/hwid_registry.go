@@ -0,0 +1,271 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// * 系统信息采集器注册表
+// * 每个 Collector 负责某个操作系统下某一槽位的一种获取方式，
+// * 同一槽位按注册顺序依次尝试，首个有效值胜出；各槽位并发采集，
+// * 每次尝试与每个槽位均有独立时限，保证整体采集时间有界。
+
+// SysInfoSlotCount V1.1 规范中的信息槽位数量
+const SysInfoSlotCount = 5
+
+// ErrElevationRequired 采集器因权限不足失败时返回（可用 errors.Is 判断）
+var ErrElevationRequired = errors.New("需要提权")
+
+// permissionAsElevation 文件读取因权限不足（EACCES/EPERM）失败时转为 ErrElevationRequired，其余错误原样返回
+func permissionAsElevation(err error) error {
+	if err != nil && errors.Is(err, os.ErrPermission) && !errors.Is(err, ErrElevationRequired) {
+		return fmt.Errorf("%w: %v", ErrElevationRequired, err)
+	}
+	return err
+}
+
+// ErrAttemptTimeout 单次尝试超出时限
+var ErrAttemptTimeout = errors.New("采集超时")
+
+const (
+	// DefaultAttemptTimeout 单次尝试的默认时限（PowerShell WMI、system_profiler 偶尔会卡死）
+	DefaultAttemptTimeout = 10 * time.Second
+	// DefaultSlotTimeout 单个槽位（含全部回退来源）的默认时限，亦即整体采集时间上限
+	DefaultSlotTimeout = 25 * time.Second
+)
+
+// Collector 单个系统信息来源
+type Collector interface {
+	// Name 来源描述（文件路径或命令），用于日志
+	Name() string
+	// Slot 所属槽位，0 ~ SysInfoSlotCount-1
+	Slot() int
+	// OS 适用的操作系统（与 runtime.GOOS 取值一致）
+	OS() string
+	// RequiresElevation 该来源失败时是否视为需要提权后重试
+	RequiresElevation() bool
+	// Attempt 执行一次获取，返回规范化后的值；应在 ctx 结束时尽快返回
+	Attempt(ctx context.Context) (string, error)
+}
+
+// funcCollector 以函数实现的通用采集器
+type funcCollector struct {
+	name    string
+	slot    int
+	goos    string
+	elevate bool
+	attempt func(ctx context.Context) (string, error)
+}
+
+func (c *funcCollector) Name() string            { return c.name }
+func (c *funcCollector) Slot() int               { return c.slot }
+func (c *funcCollector) OS() string              { return c.goos }
+func (c *funcCollector) RequiresElevation() bool { return c.elevate }
+func (c *funcCollector) Attempt(ctx context.Context) (string, error) {
+	return c.attempt(ctx)
+}
+
+// NewCollector 由函数构造采集器，便于扩展来源或在测试中注入假数据
+func NewCollector(name string, slot int, goos string, requiresElevation bool, attempt func(ctx context.Context) (string, error)) Collector {
+	return &funcCollector{name: name, slot: slot, goos: goos, elevate: requiresElevation, attempt: attempt}
+}
+
+// SlotResult 单个槽位的采集结果
+type SlotResult struct {
+	Value       string   `json:"value"`        // 规范化后的值，失败时为 SysInfoUnavailable
+	Source      string   `json:"source"`       // 产生该值的来源，失败时为空
+	Failures    []string `json:"failures"`     // 各次失败尝试的原因
+	NeedElevate bool     `json:"need_elevate"` // 是否需要提权后重新采集
+}
+
+// Registry 按操作系统分组的采集器注册表
+type Registry struct {
+	mu   sync.RWMutex
+	byOS map[string][]Collector
+
+	AttemptTimeout time.Duration // 单次尝试时限
+	SlotTimeout    time.Duration // 单个槽位时限
+}
+
+// NewRegistry 创建空注册表（使用默认时限）
+func NewRegistry() *Registry {
+	return &Registry{
+		byOS:           make(map[string][]Collector),
+		AttemptTimeout: DefaultAttemptTimeout,
+		SlotTimeout:    DefaultSlotTimeout,
+	}
+}
+
+// DefaultRegistry 默认注册表，V1.1 内置来源在 init 中注册；测试可整体替换
+var DefaultRegistry = NewRegistry()
+
+// RegisterCollector 向默认注册表注册采集器
+func RegisterCollector(c Collector) {
+	DefaultRegistry.Register(c)
+}
+
+// Register 注册采集器，同一槽位内按注册顺序尝试
+func (r *Registry) Register(c Collector) {
+	if c.Slot() < 0 || c.Slot() >= SysInfoSlotCount {
+		panic(fmt.Sprintf("采集器 %q 槽位越界: %d", c.Name(), c.Slot()))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byOS[c.OS()] = append(r.byOS[c.OS()], c)
+}
+
+// Collectors 返回指定系统、指定槽位的采集器（按尝试顺序）
+func (r *Registry) Collectors(goos string, slot int) []Collector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []Collector
+	for _, c := range r.byOS[goos] {
+		if c.Slot() == slot {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Collect 并发采集全部槽位，ctx 取消时尚未完成的槽位按失败处理
+func (r *Registry) Collect(ctx context.Context, goos string) []SlotResult {
+	ctx = withCollectMemo(ctx)
+	results := make([]SlotResult, SysInfoSlotCount)
+	var wg sync.WaitGroup
+	for slot := range results {
+		wg.Add(1)
+		go func(slot int) {
+			defer wg.Done()
+			results[slot] = r.collectSlot(ctx, goos, slot)
+		}(slot)
+	}
+	wg.Wait()
+	return results
+}
+
+type noElevationKey struct{}
+
+// withoutElevation 标记本次采集不会提权（never 策略）：权限错误不再中止槽位，而是继续尝试回退来源
+func withoutElevation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noElevationKey{}, true)
+}
+
+func elevationDisabled(ctx context.Context) bool {
+	v, _ := ctx.Value(noElevationKey{}).(bool)
+	return v
+}
+
+// collectSlot 按顺序尝试槽位内的采集器：
+// 权限错误立即中止并要求提权，以免回退来源的值替代特权来源（never 策略下记录后继续回退，槽位仍标记需提权）；
+// 超时记为失败并继续下一个来源；全部失败时若有来源声明需提权（超时除外），同样要求提权
+func (r *Registry) collectSlot(ctx context.Context, goos string, slot int) SlotResult {
+	infoName := slotName(slot)
+	res := SlotResult{Value: SysInfoUnavailable}
+	elevate := false
+	fallback := elevationDisabled(ctx)
+	ctx, cancel := context.WithTimeout(ctx, r.SlotTimeout)
+	defer cancel()
+	AppendTestLog("collect " + goos + " " + infoName)
+	for i, c := range r.Collectors(goos, slot) {
+		if ctx.Err() != nil {
+			res.Failures = append(res.Failures, fmt.Sprintf("%s: %v", c.Name(), ctx.Err()))
+			continue
+		}
+		AppendTestLog(fmt.Sprintf("  %s attempt %d: %s", infoName, i+1, c.Name()))
+		id, err := r.attempt(ctx, c)
+		if err != nil {
+			AppendTestLog(fmt.Sprintf("    %s error: %v", infoName, err))
+			res.Failures = append(res.Failures, fmt.Sprintf("%s: %v", c.Name(), err))
+			if errors.Is(err, ErrElevationRequired) {
+				res.NeedElevate = true
+				if !fallback {
+					return res
+				}
+				continue
+			}
+			if !errors.Is(err, ErrAttemptTimeout) {
+				elevate = elevate || c.RequiresElevation()
+			}
+			continue
+		}
+		if id != SysInfoUnavailable {
+			res.Value = id
+			res.Source = c.Name()
+			return res
+		}
+		res.Failures = append(res.Failures, c.Name()+": 空值")
+		elevate = elevate || c.RequiresElevation()
+	}
+	AppendTestLog("  all attempts failed for " + infoName + ", needElevate: " + fmt.Sprint(elevate))
+	res.NeedElevate = res.NeedElevate || elevate
+	return res
+}
+
+// attempt 在独立时限内执行一次尝试；采集器未响应 ctx 时也会按时返回
+func (r *Registry) attempt(ctx context.Context, c Collector) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.AttemptTimeout)
+	defer cancel()
+	type result struct {
+		id  string
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		id, err := c.Attempt(ctx)
+		ch <- result{id, err}
+	}()
+	select {
+	case res := <-ch:
+		if res.err != nil && ctx.Err() != nil {
+			return SysInfoUnavailable, fmt.Errorf("%w: %v", ErrAttemptTimeout, res.err)
+		}
+		return res.id, res.err
+	case <-ctx.Done():
+		return SysInfoUnavailable, fmt.Errorf("%w: %v", ErrAttemptTimeout, ctx.Err())
+	}
+}
+
+// collectMemo 一次 Collect 内各来源共享的结果缓存（如 SMBIOS 表只读取、解析一次）
+type collectMemo struct {
+	mu      sync.Mutex
+	entries map[string]*memoEntry
+}
+
+type memoEntry struct {
+	once sync.Once
+	v    any
+	err  error
+}
+
+type collectMemoKey struct{}
+
+func withCollectMemo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, collectMemoKey{}, &collectMemo{entries: make(map[string]*memoEntry)})
+}
+
+// memoize 同一次 Collect 内相同 key 的 fn 只执行一次，并发调用者等待并共享结果；ctx 不属于 Collect 时直接执行
+func memoize[T any](ctx context.Context, key string, fn func() (T, error)) (T, error) {
+	m, ok := ctx.Value(collectMemoKey{}).(*collectMemo)
+	if !ok {
+		return fn()
+	}
+	m.mu.Lock()
+	e := m.entries[key]
+	if e == nil {
+		e = &memoEntry{}
+		m.entries[key] = e
+	}
+	m.mu.Unlock()
+	e.once.Do(func() { e.v, e.err = fn() })
+	v, _ := e.v.(T)
+	return v, e.err
+}
+
+// slotName 槽位的日志名（info1 ~ info5）
+func slotName(slot int) string {
+	return fmt.Sprintf("info%d", slot+1)
+}
@@ -0,0 +1,439 @@
+package internal
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// callLog 记录假采集器被调用的顺序（各槽位并发采集）
+type callLog struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (l *callLog) add(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.names = append(l.names, name)
+}
+
+func (l *callLog) list() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.names)
+}
+
+// fakeCollector 注册一个返回固定结果的假采集器
+func fakeCollector(r *Registry, calls *callLog, name string, slot int, elevate bool, value string, err error) {
+	r.Register(NewCollector(name, slot, "test", elevate, func(ctx context.Context) (string, error) {
+		calls.add(name)
+		return value, err
+	}))
+}
+
+func TestRegistryCollectorsOrder(t *testing.T) {
+	r := NewRegistry()
+	var calls callLog
+	fakeCollector(r, &calls, "b0", 0, false, "B0", nil)
+	fakeCollector(r, &calls, "a1", 1, false, "A1", nil)
+	fakeCollector(r, &calls, "a0", 0, false, "A0", nil)
+	fakeCollector(r, &calls, "c0", 0, false, "C0", nil)
+	r.Register(NewCollector("other-os", 0, "other", false, func(ctx context.Context) (string, error) { return "X", nil }))
+
+	var names []string
+	for _, c := range r.Collectors("test", 0) {
+		names = append(names, c.Name())
+	}
+	if want := []string{"b0", "a0", "c0"}; !slices.Equal(names, want) {
+		t.Fatalf("槽位 0 采集器顺序: 期望 %v, 得到 %v", want, names)
+	}
+	if n := len(r.Collectors("test", 2)); n != 0 {
+		t.Fatalf("未注册的槽位应为空, 得到 %d 个", n)
+	}
+
+	t.Chdir(t.TempDir())
+	results := r.Collect(context.Background(), "test")
+	if len(results) != SysInfoSlotCount {
+		t.Fatalf("结果数量: 期望 %d, 得到 %d", SysInfoSlotCount, len(results))
+	}
+	if results[0].Value != "B0" || results[0].Source != "b0" || results[1].Value != "A1" {
+		t.Fatalf("首个注册的来源应胜出: %+v", results[:2])
+	}
+	for slot := 2; slot < SysInfoSlotCount; slot++ {
+		if results[slot].Value != SysInfoUnavailable || results[slot].NeedElevate {
+			t.Fatalf("%s: 无采集器时应为不可用且无需提权: %+v", slotName(slot), results[slot])
+		}
+	}
+	if got := calls.list(); slices.Contains(got, "a0") || slices.Contains(got, "c0") {
+		t.Fatalf("首个来源成功后不应继续尝试: %v", got)
+	}
+}
+
+func TestRegistryRegisterRejectsSlotOutOfRange(t *testing.T) {
+	for _, slot := range []int{-1, SysInfoSlotCount} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("槽位 %d 应 panic", slot)
+				}
+			}()
+			NewRegistry().Register(NewCollector("bad", slot, "test", false, nil))
+		}()
+	}
+}
+
+func TestCollectSlotFallback(t *testing.T) {
+	notFound := &fs.PathError{Op: "open", Path: "/sys/class/dmi/id/board_serial", Err: syscall.ENOENT}
+	denied := permissionAsElevation(&fs.PathError{Op: "open", Path: "/sys/class/dmi/id/product_uuid", Err: syscall.EACCES})
+	type src struct {
+		name    string
+		elevate bool
+		value   string
+		err     error
+	}
+	tests := []struct {
+		name        string
+		sources     []src
+		noElevation bool
+		value       string
+		source      string
+		calls       []string
+		failures    int
+		needElevate bool
+	}{
+		{
+			name:    "出错时回退到下一个来源",
+			sources: []src{{name: "file", value: SysInfoUnavailable, err: notFound}, {name: "smbios", value: "SERIAL01"}},
+			value:   "SERIAL01", source: "smbios", calls: []string{"file", "smbios"}, failures: 1,
+		},
+		{
+			name:    "空值时回退到下一个来源",
+			sources: []src{{name: "file", value: SysInfoUnavailable}, {name: "smbios", value: "SERIAL01"}},
+			value:   "SERIAL01", source: "smbios", calls: []string{"file", "smbios"}, failures: 1,
+		},
+		{
+			name:    "占位值时回退到下一个来源",
+			sources: []src{{name: "file", value: SysInfoUnavailable, err: ErrJunkValue}, {name: "smbios", value: "SERIAL01"}},
+			value:   "SERIAL01", source: "smbios", calls: []string{"file", "smbios"}, failures: 1,
+		},
+		{
+			name:    "权限不足立即中止并要求提权",
+			sources: []src{{name: "file", value: SysInfoUnavailable, err: denied}, {name: "smbios", value: "SERIAL01"}},
+			value:   SysInfoUnavailable, calls: []string{"file"}, failures: 1, needElevate: true,
+		},
+		{
+			name:        "never 策略下权限不足继续回退, 槽位仍标记需提权",
+			sources:     []src{{name: "file", value: SysInfoUnavailable, err: denied}, {name: "smbios", value: "SERIAL01"}},
+			noElevation: true,
+			value:       "SERIAL01", source: "smbios", calls: []string{"file", "smbios"}, failures: 1, needElevate: true,
+		},
+		{
+			name:    "全部失败且有来源声明需提权",
+			sources: []src{{name: "file", value: SysInfoUnavailable, err: notFound}, {name: "system_profiler", elevate: true, value: SysInfoUnavailable, err: errors.New("exit status 1")}},
+			value:   SysInfoUnavailable, calls: []string{"file", "system_profiler"}, failures: 2, needElevate: true,
+		},
+		{
+			name:    "全部失败且无来源声明需提权",
+			sources: []src{{name: "file", value: SysInfoUnavailable, err: notFound}, {name: "lsblk", value: SysInfoUnavailable}},
+			value:   SysInfoUnavailable, calls: []string{"file", "lsblk"}, failures: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			r := NewRegistry()
+			var calls callLog
+			for _, s := range tt.sources {
+				fakeCollector(r, &calls, s.name, 0, s.elevate, s.value, s.err)
+			}
+			ctx := context.Background()
+			if tt.noElevation {
+				ctx = withoutElevation(ctx)
+			}
+			res := r.collectSlot(ctx, "test", 0)
+			if res.Value != tt.value || res.Source != tt.source {
+				t.Fatalf("值/来源: 期望 %q/%q, 得到 %q/%q", tt.value, tt.source, res.Value, res.Source)
+			}
+			if got := calls.list(); !slices.Equal(got, tt.calls) {
+				t.Fatalf("调用顺序: 期望 %v, 得到 %v", tt.calls, got)
+			}
+			if len(res.Failures) != tt.failures {
+				t.Fatalf("失败记录: 期望 %d 条, 得到 %v", tt.failures, res.Failures)
+			}
+			if res.NeedElevate != tt.needElevate {
+				t.Fatalf("NeedElevate: 期望 %v, 得到 %v", tt.needElevate, res.NeedElevate)
+			}
+		})
+	}
+}
+
+func TestMemoizeSharedWithinCollect(t *testing.T) {
+	t.Chdir(t.TempDir())
+	var tableReads, otherReads atomic.Int32
+	r := NewRegistry()
+	for slot := 0; slot < SysInfoSlotCount; slot++ {
+		r.Register(NewCollector("smbios", slot, "test", false, func(ctx context.Context) (string, error) {
+			v, err := memoize(ctx, "table", func() (string, error) {
+				tableReads.Add(1)
+				return "TABLE", nil
+			})
+			if err != nil {
+				return SysInfoUnavailable, err
+			}
+			// 不同 key 互不共享
+			w, _ := memoize(ctx, "other", func() (int, error) {
+				otherReads.Add(1)
+				return 7, nil
+			})
+			if w != 7 {
+				return SysInfoUnavailable, errors.New("缓存值类型错误")
+			}
+			return v, nil
+		}))
+	}
+	for _, res := range r.Collect(context.Background(), "test") {
+		if res.Value != "TABLE" {
+			t.Fatalf("槽位结果: %+v", res)
+		}
+	}
+	if tableReads.Load() != 1 || otherReads.Load() != 1 {
+		t.Fatalf("一次 Collect 内每个 key 只应执行一次, 实际 %d、%d 次", tableReads.Load(), otherReads.Load())
+	}
+
+	// 失败结果同样共享，不会在同一次 Collect 内重试
+	var failures atomic.Int32
+	r = NewRegistry()
+	for slot := 0; slot < SysInfoSlotCount; slot++ {
+		r.Register(NewCollector("smbios", slot, "test", false, func(ctx context.Context) (string, error) {
+			_, err := memoize(ctx, "table", func() (*SMBIOS, error) {
+				failures.Add(1)
+				return nil, errors.New("读取失败")
+			})
+			return SysInfoUnavailable, err
+		}))
+	}
+	r.Collect(context.Background(), "test")
+	if n := failures.Load(); n != 1 {
+		t.Fatalf("失败结果应共享, 实际执行 %d 次", n)
+	}
+
+	// 不属于 Collect 的 ctx 不缓存
+	var direct atomic.Int32
+	for range 2 {
+		memoize(context.Background(), "table", func() (string, error) {
+			direct.Add(1)
+			return "", nil
+		})
+	}
+	if n := direct.Load(); n != 2 {
+		t.Fatalf("Collect 之外不应缓存, 实际执行 %d 次", n)
+	}
+}
+
+// goldenSource 重构前采集函数的一次来源输出
+type goldenSource struct {
+	Output     string `json:"output"`
+	SkipHeader int    `json:"skip_header"`
+	Error      string `json:"error"`
+}
+
+// testdata/registry/v1_1_golden.json 的期望值由重构前（0c4807a）的 getAndNormalizeInfo 与逐来源回退逻辑生成后冻结，
+// 经注册表采集得到的 V1.1 信息与绑定密钥必须与之逐字节一致，否则已激活的机器会失配
+func TestRegistryV1_1Golden(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "registry", "v1_1_golden.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var golden struct {
+		Salt  string `json:"salt"`
+		Cases []struct {
+			Name    string           `json:"name"`
+			GOOS    string           `json:"goos"`
+			Slots   [][]goldenSource `json:"slots"`
+			Infos   []string         `json:"infos"`
+			BindKey string           `json:"bind_key"`
+		} `json:"cases"`
+	}
+	if err := json.Unmarshal(raw, &golden); err != nil {
+		t.Fatal(err)
+	}
+	if len(golden.Cases) == 0 {
+		t.Fatal("缺少黄金样本")
+	}
+	for _, tc := range golden.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			r := NewRegistry()
+			for slot, sources := range tc.Slots {
+				for i, s := range sources {
+					// 与 registerWin / registerLinux 相同：先按 skipHeader 取值，再 normalizeInfo
+					r.Register(NewCollector(fmt.Sprintf("%s 来源 %d", slotName(slot), i+1), slot, tc.GOOS, false, func(ctx context.Context) (string, error) {
+						if s.Error != "" {
+							return SysInfoUnavailable, errors.New(s.Error)
+						}
+						return normalizeInfo(ParsePowerShellValue(s.Output, s.SkipHeader), 0)
+					}))
+				}
+			}
+			infos := SysInfoValues(r.Collect(context.Background(), tc.GOOS))
+			if !slices.Equal(infos, tc.Infos) {
+				t.Fatalf("信息: 期望 %q, 得到 %q", tc.Infos, infos)
+			}
+			key := hex.EncodeToString(DeriveSysInfoBindKeyV1_1FromInfos([]byte(golden.Salt), infos))
+			if key != tc.BindKey {
+				t.Fatalf("绑定密钥: 期望 %s, 得到 %s", tc.BindKey, key)
+			}
+		})
+	}
+}
+
+// blockingCollector 阻塞直到 ctx 结束；ignoreCtx 时不理会 ctx，直到 release 关闭才返回
+func blockingCollector(name string, slot int, elevate, ignoreCtx bool, release <-chan struct{}, calls *atomic.Int32) Collector {
+	return NewCollector(name, slot, "test", elevate, func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		if ignoreCtx {
+			<-release
+			return SysInfoUnavailable, errors.New("卡死")
+		}
+		<-ctx.Done()
+		return SysInfoUnavailable, ctx.Err()
+	})
+}
+
+// waitGoroutines 等待协程数回落到 base，确认没有残留的阻塞协程
+func waitGoroutines(t *testing.T, base int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > base {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("残留协程 %d 个（基线 %d）:\n%s", runtime.NumGoroutine(), base, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	for _, ignoreCtx := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ignoreCtx=%v", ignoreCtx), func(t *testing.T) {
+			base := runtime.NumGoroutine()
+			release := make(chan struct{})
+			var calls atomic.Int32
+			r := NewRegistry()
+			r.AttemptTimeout = 50 * time.Millisecond
+			start := time.Now()
+			_, err := r.attempt(context.Background(), blockingCollector("hang", 0, false, ignoreCtx, release, &calls))
+			if !errors.Is(err, ErrAttemptTimeout) {
+				t.Fatalf("期望 ErrAttemptTimeout, 得到 %v", err)
+			}
+			if d := time.Since(start); d > time.Second {
+				t.Fatalf("超时后应按时返回, 实际耗时 %v", d)
+			}
+			// 未理会 ctx 的采集器在返回后结果写入带缓冲的通道，协程随之退出
+			close(release)
+			waitGoroutines(t, base)
+		})
+	}
+}
+
+func TestCollectSlotTimeoutFallsThrough(t *testing.T) {
+	t.Chdir(t.TempDir())
+	base := runtime.NumGoroutine()
+	release := make(chan struct{})
+	var hung atomic.Int32
+	var calls callLog
+	r := NewRegistry()
+	r.AttemptTimeout = 50 * time.Millisecond
+	// 超时的来源即使声明需提权，也不因超时而要求提权
+	r.Register(blockingCollector("system_profiler", 0, true, true, release, &hung))
+	fakeCollector(r, &calls, "ioreg", 0, true, "SERIAL01", nil)
+	r.Register(blockingCollector("system_profiler", 1, true, false, nil, &hung))
+
+	res := r.collectSlot(context.Background(), "test", 0)
+	if res.Value != "SERIAL01" || res.Source != "ioreg" {
+		t.Fatalf("超时后应回退到下一个来源: %+v", res)
+	}
+	if len(res.Failures) != 1 || !strings.Contains(res.Failures[0], ErrAttemptTimeout.Error()) {
+		t.Fatalf("失败记录应为超时: %v", res.Failures)
+	}
+	res = r.collectSlot(context.Background(), "test", 1)
+	if res.Value != SysInfoUnavailable || res.NeedElevate {
+		t.Fatalf("仅超时的槽位不应要求提权: %+v", res)
+	}
+	if n := hung.Load(); n != 2 {
+		t.Fatalf("阻塞来源调用 %d 次", n)
+	}
+	close(release)
+	waitGoroutines(t, base)
+}
+
+func TestCollectSlotTimeout(t *testing.T) {
+	t.Chdir(t.TempDir())
+	var hung atomic.Int32
+	r := NewRegistry()
+	r.AttemptTimeout = time.Minute
+	r.SlotTimeout = 100 * time.Millisecond
+	for range 3 {
+		r.Register(blockingCollector("hang", 0, false, false, nil, &hung))
+	}
+	start := time.Now()
+	res := r.collectSlot(context.Background(), "test", 0)
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("槽位应在时限内结束, 实际耗时 %v", d)
+	}
+	if n := hung.Load(); n != 1 {
+		t.Fatalf("槽位超时后不应再尝试其余来源, 实际调用 %d 次", n)
+	}
+	if res.Value != SysInfoUnavailable || len(res.Failures) != 3 {
+		t.Fatalf("其余来源应记为失败: %+v", res)
+	}
+	for _, f := range res.Failures[1:] {
+		if !strings.Contains(f, context.DeadlineExceeded.Error()) {
+			t.Fatalf("失败原因应为槽位超时: %q", f)
+		}
+	}
+}
+
+func TestCollectParentCancel(t *testing.T) {
+	t.Chdir(t.TempDir())
+	base := runtime.NumGoroutine()
+	var hung atomic.Int32
+	r := NewRegistry()
+	for slot := 0; slot < SysInfoSlotCount; slot++ {
+		r.Register(blockingCollector("hang", slot, true, false, nil, &hung))
+		r.Register(blockingCollector("fallback", slot, true, false, nil, &hung))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	results := r.Collect(ctx, "test")
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("取消后 Collect 应尽快返回, 实际耗时 %v", d)
+	}
+	for slot, res := range results {
+		if res.Value != SysInfoUnavailable || res.NeedElevate {
+			t.Fatalf("%s: 取消后应为不可用且不要求提权: %+v", slotName(slot), res)
+		}
+		if len(res.Failures) != 2 || !strings.Contains(res.Failures[1], context.Canceled.Error()) {
+			t.Fatalf("%s: 取消后不应再尝试回退来源: %v", slotName(slot), res.Failures)
+		}
+	}
+	if n := hung.Load(); n != SysInfoSlotCount {
+		t.Fatalf("每个槽位只应尝试一次, 实际 %d 次", n)
+	}
+	waitGoroutines(t, base)
+}
@@ -0,0 +1,281 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// * 根文件系统所在物理磁盘解析（Linux）
+// * mountinfo 定位根设备，沿 /sys/block/*/slaves 向下穿过 LVM、dm-crypt、md RAID，
+// * slaves 不可用时再从物理磁盘沿 holders 向上匹配，最终从物理磁盘读取序列号或 WWID。
+
+// rootDiskProbe 以 root 为前缀访问 /proc、/sys、/run，便于在合成目录树上运行
+type rootDiskProbe struct {
+	root string
+}
+
+var defaultRootDiskProbe = rootDiskProbe{root: "/"}
+
+func (p rootDiskProbe) path(elem ...string) string {
+	return filepath.Join(append([]string{p.root}, elem...)...)
+}
+
+// rootDiskSerial 返回根文件系统所在物理磁盘的序列号（多盘阵列时取首个可读的）
+func rootDiskSerial(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return defaultRootDiskProbe.serial()
+}
+
+func (p rootDiskProbe) serial() (string, error) {
+	disks, err := p.rootDisks()
+	if err != nil {
+		return "", err
+	}
+	var failures []string
+	for _, d := range disks {
+		s, err := p.diskSerial(d)
+		if err == nil {
+			return s, nil
+		}
+		failures = append(failures, err.Error())
+	}
+	return "", fmt.Errorf("根磁盘 %v 均无可用序列号: %s", disks, strings.Join(failures, "; "))
+}
+
+// rootDisks 返回承载根文件系统的物理磁盘名（如 sda、nvme1n1、vda），按名称排序
+func (p rootDiskProbe) rootDisks() ([]string, error) {
+	dev, err := p.rootBlockDevice()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var disks []string
+	for _, leaf := range p.slaveLeaves(dev, map[string]bool{}) {
+		disk := p.parentDisk(leaf)
+		if !seen[disk] && !isVirtualBlockDevice(disk) {
+			seen[disk] = true
+			disks = append(disks, disk)
+		}
+	}
+	if len(disks) == 0 {
+		disks = p.holderDisks(dev)
+	}
+	if len(disks) == 0 {
+		return nil, errors.New("无法确定 " + dev + " 的物理磁盘")
+	}
+	sort.Strings(disks)
+	return disks, nil
+}
+
+// rootBlockDevice 解析 mountinfo 中挂载点为 / 的条目，返回对应的块设备名
+func (p rootDiskProbe) rootBlockDevice() (string, error) {
+	f, err := os.Open(p.path("proc/self/mountinfo"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var majMin, source string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// 格式: id parent maj:min root mountpoint opts [optional...] - fstype source superopts
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 5 || fields[4] != "/" {
+			continue
+		}
+		majMin, source = fields[2], ""
+		for i := 5; i < len(fields)-2; i++ {
+			if fields[i] == "-" {
+				source = fields[i+2]
+				break
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	if majMin == "" {
+		return "", errors.New("mountinfo 中没有根文件系统")
+	}
+	if name := p.blockByDevNumber(majMin); name != "" {
+		return name, nil
+	}
+	// btrfs 等文件系统使用匿名设备号，退回按挂载源名称查找
+	if name := p.blockBySource(source); name != "" {
+		return name, nil
+	}
+	return "", fmt.Errorf("根文件系统 %s (%s) 不在块设备上", source, majMin)
+}
+
+func (p rootDiskProbe) blockByDevNumber(majMin string) string {
+	entries, _ := os.ReadDir(p.path("sys/class/block"))
+	for _, e := range entries {
+		if dev, err := readSysFile(p.path("sys/class/block", e.Name(), "dev")); err == nil && strings.TrimSpace(dev) == majMin {
+			return e.Name()
+		}
+	}
+	return ""
+}
+
+func (p rootDiskProbe) blockBySource(source string) string {
+	if !strings.HasPrefix(source, "/dev/") {
+		return ""
+	}
+	if mapped, ok := strings.CutPrefix(source, "/dev/mapper/"); ok {
+		entries, _ := os.ReadDir(p.path("sys/class/block"))
+		for _, e := range entries {
+			if name, err := readSysFile(p.path("sys/class/block", e.Name(), "dm/name")); err == nil && strings.TrimSpace(name) == mapped {
+				return e.Name()
+			}
+		}
+		return ""
+	}
+	name := filepath.Base(source)
+	if _, err := os.Stat(p.path("sys/class/block", name)); err == nil {
+		return name
+	}
+	return ""
+}
+
+// slaveLeaves 沿 slaves 递归到最底层设备（可能是分区）
+func (p rootDiskProbe) slaveLeaves(dev string, visiting map[string]bool) []string {
+	if visiting[dev] {
+		return nil
+	}
+	visiting[dev] = true
+	entries, _ := os.ReadDir(p.path("sys/class/block", dev, "slaves"))
+	if len(entries) == 0 {
+		return []string{dev}
+	}
+	var leaves []string
+	for _, e := range entries {
+		leaves = append(leaves, p.slaveLeaves(e.Name(), visiting)...)
+	}
+	return leaves
+}
+
+// parentDisk 分区映射到所属磁盘（/sys/block/<disk>/<part>），非分区原样返回
+func (p rootDiskProbe) parentDisk(dev string) string {
+	if _, err := os.Stat(p.path("sys/block", dev)); err == nil {
+		return dev
+	}
+	disks, _ := os.ReadDir(p.path("sys/block"))
+	for _, d := range disks {
+		if _, err := os.Stat(p.path("sys/block", d.Name(), dev, "partition")); err == nil {
+			return d.Name()
+		}
+	}
+	return dev
+}
+
+// holderDisks 反向查找：从每块物理磁盘及其分区沿 holders 向上，能到达 dev 的即为其后备磁盘
+func (p rootDiskProbe) holderDisks(dev string) []string {
+	var out []string
+	disks, _ := os.ReadDir(p.path("sys/block"))
+	for _, d := range disks {
+		if isVirtualBlockDevice(d.Name()) {
+			continue
+		}
+		starts := []string{d.Name()}
+		parts, _ := os.ReadDir(p.path("sys/block", d.Name()))
+		for _, part := range parts {
+			if _, err := os.Stat(p.path("sys/block", d.Name(), part.Name(), "partition")); err == nil {
+				starts = append(starts, part.Name())
+			}
+		}
+		for _, s := range starts {
+			if p.reachesViaHolders(s, dev, map[string]bool{}) {
+				out = append(out, d.Name())
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (p rootDiskProbe) reachesViaHolders(from, target string, visiting map[string]bool) bool {
+	if from == target {
+		return true
+	}
+	if visiting[from] {
+		return false
+	}
+	visiting[from] = true
+	holders, _ := os.ReadDir(p.path("sys/class/block", from, "holders"))
+	for _, h := range holders {
+		if p.reachesViaHolders(h.Name(), target, visiting) {
+			return true
+		}
+	}
+	return false
+}
+
+// isVirtualBlockDevice 不对应物理介质的块设备
+func isVirtualBlockDevice(name string) bool {
+	for _, prefix := range []string{"dm-", "md", "loop", "ram", "zram", "nbd"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// diskSerial 依次尝试磁盘的序列号与 WWID 来源；/sys/block/<disk>/serial 优先以保持既有指纹
+func (p rootDiskProbe) diskSerial(disk string) (string, error) {
+	for _, rel := range []string{"serial", "device/serial", "device/vpd_pg80", "wwid", "device/wwid"} {
+		raw, err := readSysFile(p.path("sys/block", disk, rel))
+		if err != nil {
+			continue
+		}
+		if rel == "device/vpd_pg80" {
+			serial, ok := parseVPDPage80([]byte(raw))
+			if !ok {
+				continue
+			}
+			raw = serial
+		}
+		if s := strings.TrimSpace(strings.Trim(raw, "\x00")); s != "" {
+			return s, nil
+		}
+	}
+	// 与 lsblk 相同的来源：udev 数据库中的 ID_SERIAL_SHORT
+	if dev, err := readSysFile(p.path("sys/block", disk, "dev")); err == nil {
+		if s := p.udevProperty("b"+strings.TrimSpace(dev), "ID_SERIAL_SHORT"); s != "" {
+			return s, nil
+		}
+	}
+	return "", errors.New(disk + " 无序列号或 WWID")
+}
+
+// parseVPDPage80 VPD 0x80 页：页代码 0x80，页长度（uint16 BE，字节 2~3）后为产品序列号；长度越界视为无效
+func parseVPDPage80(raw []byte) (string, bool) {
+	if len(raw) < 4 || raw[1] != 0x80 {
+		return "", false
+	}
+	n := int(binary.BigEndian.Uint16(raw[2:4]))
+	if n == 0 || 4+n > len(raw) {
+		return "", false
+	}
+	return string(raw[4 : 4+n]), true
+}
+
+func (p rootDiskProbe) udevProperty(id, key string) string {
+	data, err := readSysFile(p.path("run/udev/data", id))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(data, "\n") {
+		if v, ok := strings.CutPrefix(line, "E:"+key+"="); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
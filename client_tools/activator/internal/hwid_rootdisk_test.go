@@ -0,0 +1,253 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sysTree 在临时目录中构造与真实 sysfs 布局一致的最小目录树
+type sysTree struct {
+	t    *testing.T
+	root string
+}
+
+func newSysTree(t *testing.T) sysTree {
+	return sysTree{t: t, root: t.TempDir()}
+}
+
+func (s sysTree) write(rel, data string) {
+	s.t.Helper()
+	p := filepath.Join(s.root, rel)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		s.t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func (s sysTree) link(rel, target string) {
+	s.t.Helper()
+	p := filepath.Join(s.root, rel)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		s.t.Fatal(err)
+	}
+	if err := os.Symlink(target, p); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// disk 磁盘：/sys/block/<name>，/sys/class/block/<name> 链接到它
+func (s sysTree) disk(name, devnum string) {
+	s.write("sys/block/"+name+"/dev", devnum+"\n")
+	s.link("sys/class/block/"+name, "../../block/"+name)
+}
+
+// partition 分区：/sys/block/<disk>/<part>/partition
+func (s sysTree) partition(disk, part, devnum string) {
+	s.write("sys/block/"+disk+"/"+part+"/dev", devnum+"\n")
+	s.write("sys/block/"+disk+"/"+part+"/partition", "1\n")
+	s.link("sys/class/block/"+part, "../../block/"+disk+"/"+part)
+}
+
+// stacked dm/md 设备，slaves 为下层设备；dmName 非空时写入 dm/name
+func (s sysTree) stacked(name, devnum, dmName string, slaves ...string) {
+	s.disk(name, devnum)
+	if dmName != "" {
+		s.write("sys/block/"+name+"/dm/name", dmName+"\n")
+	}
+	for _, sl := range slaves {
+		s.write("sys/block/"+name+"/slaves/"+sl, "")
+	}
+}
+
+func (s sysTree) rootMount(devnum, source string) {
+	s.write("proc/self/mountinfo",
+		"22 1 0:21 / /proc rw,nosuid - proc proc rw\n"+
+			"25 1 "+devnum+" / / rw,relatime shared:1 - ext4 "+source+" rw\n")
+}
+
+func vpdPage80(serial string, declared int) string {
+	return string([]byte{0x00, 0x80, byte(declared >> 8), byte(declared)}) + serial
+}
+
+func TestRootDiskProbe(t *testing.T) {
+	tests := []struct {
+		name    string
+		build   func(s sysTree)
+		disks   []string
+		serial  string
+		wantErr string
+	}{
+		{
+			name: "virtio vda1",
+			build: func(s sysTree) {
+				s.disk("vda", "253:0")
+				s.partition("vda", "vda1", "253:1")
+				s.write("sys/block/vda/serial", "VIRTIO-ROOT01\n")
+				s.rootMount("253:1", "/dev/vda1")
+			},
+			disks:  []string{"vda"},
+			serial: "VIRTIO-ROOT01",
+		},
+		{
+			name: "xen xvda1 无序列号",
+			build: func(s sysTree) {
+				s.disk("xvda", "202:0")
+				s.partition("xvda", "xvda1", "202:1")
+				s.rootMount("202:1", "/dev/xvda1")
+			},
+			disks:   []string{"xvda"},
+			wantErr: "无可用序列号",
+		},
+		{
+			name: "xen xvda 经 udev ID_SERIAL_SHORT",
+			build: func(s sysTree) {
+				s.disk("xvda", "202:0")
+				s.partition("xvda", "xvda1", "202:1")
+				s.write("run/udev/data/b202:0", "S:disk/by-id/xen-vol0\nE:ID_SERIAL_SHORT=vol-0abc123\n")
+				s.rootMount("202:1", "/dev/xvda1")
+			},
+			disks:  []string{"xvda"},
+			serial: "vol-0abc123",
+		},
+		{
+			name: "nvme0n1p1",
+			build: func(s sysTree) {
+				s.disk("nvme0n1", "259:0")
+				s.partition("nvme0n1", "nvme0n1p1", "259:1")
+				s.write("sys/block/nvme0n1/device/serial", "S4EWNX0R123456      \n")
+				s.rootMount("259:1", "/dev/nvme0n1p1")
+			},
+			disks:  []string{"nvme0n1"},
+			serial: "S4EWNX0R123456",
+		},
+		{
+			name: "LVM 于 sda2，序列号来自 vpd_pg80",
+			build: func(s sysTree) {
+				s.disk("sda", "8:0")
+				s.partition("sda", "sda1", "8:1")
+				s.partition("sda", "sda2", "8:2")
+				s.write("sys/block/sda/device/vpd_pg80", vpdPage80("WD-WCC4N1234567", 15)+"\x00\x00")
+				s.stacked("dm-0", "254:0", "vg0-root", "sda2")
+				s.rootMount("254:0", "/dev/mapper/vg0-root")
+			},
+			disks:  []string{"sda"},
+			serial: "WD-WCC4N1234567",
+		},
+		{
+			name: "dm-crypt 上的 LVM 于 nvme0n1p3",
+			build: func(s sysTree) {
+				s.disk("nvme0n1", "259:0")
+				s.partition("nvme0n1", "nvme0n1p3", "259:3")
+				s.write("sys/block/nvme0n1/wwid", "eui.0025388b91b2c3d4\n")
+				s.stacked("dm-0", "254:0", "cryptroot", "nvme0n1p3")
+				s.stacked("dm-1", "254:1", "vg0-root", "dm-0")
+				s.rootMount("254:1", "/dev/mapper/vg0-root")
+			},
+			disks:  []string{"nvme0n1"},
+			serial: "eui.0025388b91b2c3d4",
+		},
+		{
+			name: "btrfs 匿名设备号，按 /dev/mapper 名称查找",
+			build: func(s sysTree) {
+				s.disk("sda", "8:0")
+				s.partition("sda", "sda2", "8:2")
+				s.write("sys/block/sda/serial", "ZA1B2C3D\n")
+				s.stacked("dm-0", "254:0", "cryptroot", "sda2")
+				s.rootMount("0:27", "/dev/mapper/cryptroot")
+			},
+			disks:  []string{"sda"},
+			serial: "ZA1B2C3D",
+		},
+		{
+			name: "md RAID1，首块盘无序列号时取下一块",
+			build: func(s sysTree) {
+				s.disk("sda", "8:0")
+				s.partition("sda", "sda1", "8:1")
+				s.disk("sdb", "8:16")
+				s.partition("sdb", "sdb1", "8:17")
+				s.write("sys/block/sdb/device/serial", "Z1D2E3F4\n")
+				s.stacked("md0", "9:0", "", "sda1", "sdb1")
+				s.rootMount("9:0", "/dev/md0")
+			},
+			disks:  []string{"sda", "sdb"},
+			serial: "Z1D2E3F4",
+		},
+		{
+			name: "slaves 不可用时沿 holders 反向查找",
+			build: func(s sysTree) {
+				s.disk("sda", "8:0")
+				s.partition("sda", "sda3", "8:3")
+				s.write("sys/block/sda/sda3/holders/dm-0", "")
+				s.write("sys/block/sda/serial", "HOLDER01\n")
+				s.stacked("dm-0", "254:0", "vg0-root")
+				s.rootMount("254:0", "/dev/mapper/vg0-root")
+			},
+			disks:  []string{"sda"},
+			serial: "HOLDER01",
+		},
+		{
+			name: "根文件系统不在块设备上",
+			build: func(s sysTree) {
+				s.disk("vda", "253:0")
+				s.rootMount("0:31", "overlay")
+			},
+			wantErr: "不在块设备上",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSysTree(t)
+			tt.build(s)
+			p := rootDiskProbe{root: s.root}
+			if tt.disks != nil {
+				disks, err := p.rootDisks()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if strings.Join(disks, ",") != strings.Join(tt.disks, ",") {
+					t.Fatalf("根磁盘: 期望 %v, 得到 %v", tt.disks, disks)
+				}
+			}
+			serial, err := p.serial()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("期望错误包含 %q, 得到 %v (序列号 %q)", tt.wantErr, err, serial)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if serial != tt.serial {
+				t.Fatalf("序列号: 期望 %q, 得到 %q", tt.serial, serial)
+			}
+		})
+	}
+}
+
+func TestParseVPDPage80(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		serial string
+		ok     bool
+	}{
+		{"按页长度截取", vpdPage80("SERIAL01", 8) + "trailing", "SERIAL01", true},
+		{"页长度越界", vpdPage80("SER", 8), "", false},
+		{"页长度为 0", vpdPage80("", 0), "", false},
+		{"页代码不是 0x80", "\x00\x83\x00\x04ABCD", "", false},
+		{"头部不完整", "\x00\x80\x00", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serial, ok := parseVPDPage80([]byte(tt.raw))
+			if ok != tt.ok || serial != tt.serial {
+				t.Fatalf("期望 (%q, %v), 得到 (%q, %v)", tt.serial, tt.ok, serial, ok)
+			}
+		})
+	}
+}
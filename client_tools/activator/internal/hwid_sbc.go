@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// * 单板机 / ARM 身份来源（Linux）
+// * 树莓派、Jetson 等设备没有 /sys/class/dmi/id，V1.1 的五个槽位会全部不可用；
+// * 这里以设备树、SoC 信息与 eMMC CID 作为各槽位的回退来源。
+
+// sbcProbe 以 root 为前缀读取文件，便于在合成目录树上运行
+type sbcProbe struct {
+	root string
+}
+
+var defaultSBCProbe = sbcProbe{root: "/"}
+
+func (p sbcProbe) path(rel string) string {
+	return filepath.Join(p.root, rel)
+}
+
+// deviceTreeString 读取设备树属性；属性以 NUL 结尾，多值以 NUL 分隔，取第一个值
+func (p sbcProbe) deviceTreeString(rel string) (string, error) {
+	raw, err := readSysFile(p.path(rel))
+	if err != nil {
+		return "", err
+	}
+	v, _, _ := strings.Cut(raw, "\x00")
+	if strings.TrimSpace(v) == "" {
+		return "", errors.New(rel + " 为空")
+	}
+	return v, nil
+}
+
+// cpuinfoSerial 读取 /proc/cpuinfo 中的 Serial 行（树莓派等 Broadcom SoC）
+func (p sbcProbe) cpuinfoSerial() (string, error) {
+	raw, err := readSysFile(p.path("proc/cpuinfo"))
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(raw, "\n") {
+		key, val, ok := strings.Cut(line, ":")
+		if ok && strings.TrimSpace(key) == "Serial" {
+			return strings.TrimSpace(val), nil
+		}
+	}
+	return "", errors.New("/proc/cpuinfo 中没有 Serial")
+}
+
+// socAttribute 读取 /sys/devices/soc0 下的属性（serial_number、family、machine、soc_id 等）
+func (p sbcProbe) socAttribute(name string) (string, error) {
+	return readSysFile(p.path("sys/devices/soc0/" + name))
+}
+
+// emmcCID 读取首个 eMMC/SD 设备的 CID（含厂商、产品名与序列号，出厂唯一）
+func (p sbcProbe) emmcCID() (string, error) {
+	matches, _ := filepath.Glob(p.path("sys/block/mmcblk*/device/cid"))
+	sort.Strings(matches)
+	for _, m := range matches {
+		if cid, err := readSysFile(m); err == nil && strings.TrimSpace(cid) != "" {
+			return cid, nil
+		}
+	}
+	return "", errors.New("未找到 eMMC CID")
+}
+
+// sbcSource 将 sbcProbe 方法包装为采集函数
+func sbcSource(p sbcProbe, fn func(p sbcProbe) (string, error)) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return fn(p)
+	}
+}
+
+// sbcSources 单板机回退来源，按槽位内的尝试顺序排列
+var sbcSources = []struct {
+	slot int
+	name string
+	read func(p sbcProbe) (string, error)
+}{
+	{0, "cpuinfo: Serial", sbcProbe.cpuinfoSerial},
+	{0, "soc0: serial_number", func(p sbcProbe) (string, error) { return p.socAttribute("serial_number") }},
+	{1, "device-tree: serial-number", func(p sbcProbe) (string, error) { return p.deviceTreeString("proc/device-tree/serial-number") }},
+	{2, "soc0: family", func(p sbcProbe) (string, error) { return p.socAttribute("family") }},
+	{3, "device-tree: model", func(p sbcProbe) (string, error) { return p.deviceTreeString("proc/device-tree/model") }},
+	{3, "soc0: machine", func(p sbcProbe) (string, error) { return p.socAttribute("machine") }},
+	{4, "mmc: cid", sbcProbe.emmcCID},
+}
+
+// sbcCollectors 在 p 上读取的单板机采集器，与内置来源使用相同的包装
+func sbcCollectors(p sbcProbe) []Collector {
+	out := make([]Collector, 0, len(sbcSources))
+	for _, src := range sbcSources {
+		out = append(out, linuxFuncCollector(src.slot, src.name, sbcSource(p, src.read)))
+	}
+	return out
+}
+
+// registerSBCCollectors 注册单板机回退来源（排在 DMI/SMBIOS 来源之后）
+func registerSBCCollectors() {
+	for _, c := range sbcCollectors(defaultSBCProbe) {
+		RegisterCollector(c)
+	}
+}
@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// resolveSBCSlots 经注册表采集单板机来源，与内置来源走同一包装（linuxFuncCollector）：
+// 设备树属性先在首个 NUL 处截断，原始值再经 normalizeInfo(值, 0) 去首尾空白、转大写、去除 - : 空格，
+// 占位值视为失败；槽位内按 sbcSources 顺序取首个有效值
+func resolveSBCSlots(t *testing.T, p sbcProbe) (values, sources [SysInfoSlotCount]string) {
+	t.Helper()
+	// Collect 的采集日志写入工作目录下的 log.txt
+	t.Chdir(t.TempDir())
+	r := NewRegistry()
+	for _, c := range sbcCollectors(p) {
+		r.Register(c)
+	}
+	for i, res := range r.Collect(context.Background(), "linux") {
+		values[i], sources[i] = res.Value, res.Source
+	}
+	return values, sources
+}
+
+func TestSBCSources(t *testing.T) {
+	const u = SysInfoUnavailable
+	tests := []struct {
+		name    string
+		build   func(s sysTree)
+		values  [SysInfoSlotCount]string
+		sources [SysInfoSlotCount]string
+	}{
+		{
+			name: "树莓派 4：cpuinfo Serial 与设备树",
+			build: func(s sysTree) {
+				s.write("proc/cpuinfo", "processor\t: 0\nBogoMIPS\t: 108.00\n\nHardware\t: BCM2835\nRevision\t: d03114\nSerial\t\t: 10000000a1b2c3d4\nModel\t\t: Raspberry Pi 4 Model B Rev 1.4\n")
+				s.write("proc/device-tree/serial-number", "10000000a1b2c3d4\x00")
+				s.write("proc/device-tree/model", "Raspberry Pi 4 Model B Rev 1.4\x00")
+				s.write("sys/block/mmcblk0/device/cid", "035344534331364780f1e2d3c4014b00\n")
+			},
+			values:  [SysInfoSlotCount]string{"10000000A1B2C3D4", "10000000A1B2C3D4", u, "RASPBERRYPI4MODELBREV1.4", "035344534331364780F1E2D3C4014B00"},
+			sources: [SysInfoSlotCount]string{"cpuinfo: Serial", "device-tree: serial-number", "", "device-tree: model", "mmc: cid"},
+		},
+		{
+			name: "Rockchip RK3588：设备树 serial-number，cpuinfo 无 Serial",
+			build: func(s sysTree) {
+				s.write("proc/cpuinfo", "processor\t: 0\nBogoMIPS\t: 48.00\nFeatures\t: fp asimd evtstrm aes pmull sha1 sha2 crc32\nCPU implementer\t: 0x41\nCPU part\t: 0xd05\n")
+				s.write("proc/device-tree/serial-number", "a4b2c1d8e6f70912\x00")
+				s.write("proc/device-tree/model", "Radxa ROCK 5B\x00")
+				s.write("proc/device-tree/compatible", "radxa,rock-5b\x00rockchip,rk3588\x00")
+				s.write("sys/block/mmcblk0/device/cid", "150100424a5451415204a1b2c3d4e5f6\n")
+			},
+			values:  [SysInfoSlotCount]string{u, "A4B2C1D8E6F70912", u, "RADXAROCK5B", "150100424A5451415204A1B2C3D4E5F6"},
+			sources: [SysInfoSlotCount]string{"", "device-tree: serial-number", "", "device-tree: model", "mmc: cid"},
+		},
+		{
+			name: "Jetson：设备树 serial-number，soc0 无序列号，首个 mmc 无 CID",
+			build: func(s sysTree) {
+				s.write("proc/cpuinfo", "processor\t: 0\nmodel name\t: ARMv8 Processor rev 1 (v8l)\nCPU implementer\t: 0x41\n")
+				s.write("proc/device-tree/serial-number", "1423421052891\x00")
+				s.write("proc/device-tree/model", "NVIDIA Jetson AGX Orin Developer Kit\x00")
+				s.write("sys/devices/soc0/family", "Tegra\n")
+				s.write("sys/devices/soc0/machine", "NVIDIA Jetson AGX Orin Developer Kit\n")
+				s.write("sys/devices/soc0/soc_id", "35\n")
+				s.write("sys/block/mmcblk0/device/cid", "\n")
+				s.write("sys/block/mmcblk1/device/cid", "150100444a34525e32a1b2c3d4e5f6a0\n")
+			},
+			values:  [SysInfoSlotCount]string{u, "1423421052891", "TEGRA", "NVIDIAJETSONAGXORINDEVELOPERKIT", "150100444A34525E32A1B2C3D4E5F6A0"},
+			sources: [SysInfoSlotCount]string{"", "device-tree: serial-number", "soc0: family", "device-tree: model", "mmc: cid"},
+		},
+		{
+			name: "i.MX：soc0 serial_number，无设备树 model 时取 soc0 machine",
+			build: func(s sysTree) {
+				s.write("sys/devices/soc0/family", "Freescale i.MX\n")
+				s.write("sys/devices/soc0/machine", "NXP i.MX8MQ EVK\n")
+				s.write("sys/devices/soc0/serial_number", "0C1A2B3C4D5E6F70\n")
+				s.write("sys/devices/soc0/soc_id", "i.MX8MQ\n")
+			},
+			values:  [SysInfoSlotCount]string{"0C1A2B3C4D5E6F70", u, "FREESCALEI.MX", "NXPI.MX8MQEVK", u},
+			sources: [SysInfoSlotCount]string{"soc0: serial_number", "", "soc0: family", "soc0: machine", ""},
+		},
+		{
+			name: "cpuinfo Serial 全零时回退到 soc0",
+			build: func(s sysTree) {
+				s.write("proc/cpuinfo", "Hardware\t: BCM2835\nSerial\t\t: 0000000000000000\n")
+				s.write("sys/devices/soc0/serial_number", "7A3F09C2B1D45E68\n")
+			},
+			values:  [SysInfoSlotCount]string{"7A3F09C2B1D45E68", u, u, u, u},
+			sources: [SysInfoSlotCount]string{"soc0: serial_number", "", "", "", ""},
+		},
+		{
+			name:   "所有来源均不存在",
+			build:  func(s sysTree) {},
+			values: [SysInfoSlotCount]string{u, u, u, u, u},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSysTree(t)
+			tt.build(s)
+			values, sources := resolveSBCSlots(t, sbcProbe{root: s.root})
+			if values != tt.values {
+				t.Fatalf("槽位值: 期望 %q, 得到 %q", tt.values, values)
+			}
+			if sources != tt.sources {
+				t.Fatalf("来源: 期望 %q, 得到 %q", tt.sources, sources)
+			}
+		})
+	}
+}
+
+// testdata/sbc/captured-<板卡>/ 为真机采集的文件树（root/ 下保持原路径与原始字节，含设备树末尾的 NUL），
+// expected.json 为各槽位的期望值与来源；采集方法见 testdata/sbc/README.md
+func TestSBCCapturedTrees(t *testing.T) {
+	dirs, err := filepath.Glob(filepath.Join("testdata", "sbc", "captured-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) == 0 {
+		t.Skip("尚无单板机真机采集样本，见 testdata/sbc/README.md")
+	}
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join(dir, "expected.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want struct {
+				Values  [SysInfoSlotCount]string `json:"values"`
+				Sources [SysInfoSlotCount]string `json:"sources"`
+			}
+			if err := json.Unmarshal(raw, &want); err != nil {
+				t.Fatal(err)
+			}
+			root, err := filepath.Abs(filepath.Join(dir, "root"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			values, sources := resolveSBCSlots(t, sbcProbe{root: root})
+			if values != want.Values || sources != want.Sources {
+				t.Fatalf("期望 %q / %q, 得到 %q / %q", want.Values, want.Sources, values, sources)
+			}
+		})
+	}
+}
+
+func TestSBCProbeErrors(t *testing.T) {
+	s := newSysTree(t)
+	s.write("proc/cpuinfo", "processor\t: 0\n")
+	s.write("proc/device-tree/model", "\x00")
+	p := sbcProbe{root: s.root}
+	if _, err := p.cpuinfoSerial(); err == nil {
+		t.Fatal("无 Serial 行应失败")
+	}
+	if _, err := p.deviceTreeString("proc/device-tree/model"); err == nil {
+		t.Fatal("空设备树属性应失败")
+	}
+	if _, err := p.emmcCID(); err == nil {
+		t.Fatal("无 mmc 设备应失败")
+	}
+	if _, err := p.socAttribute("serial_number"); err == nil || errors.Is(err, ErrElevationRequired) {
+		t.Fatalf("缺少 soc0 属性应为普通失败: %v", err)
+	}
+}
@@ -1,12 +1,11 @@
 package internal
 
 import (
-	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"os"
-	"os/exec"
 	"runtime"
 	"strings"
 )
@@ -22,158 +21,66 @@ const (
 
 // 获取5项系统信息，返回规范化后的字符串切片
 func GetStandardizedSysInfoV1_1() []string {
-	var infos [5]string
-	needElevate := false
-	alreadyElevated := os.Getenv("ELEVATE_FLAG") == "1"
-	AppendTestLog("GetStandardizedSysInfoV1_1 start, alreadyElevated: " + fmt.Sprint(alreadyElevated))
-
-	switch runtime.GOOS {
-	case "windows":
-		tmp, ne := getInfoWithElevateWin(
-			[]cmdSpec{
-				{"powershell", []string{"-Command", "Get-WmiObject -Class Win32_ComputerSystemProduct | Select-Object -ExpandProperty UUID"}},
-			},
-			1,
-			"info1",
-		)
-		infos[0] = tmp
-		needElevate = needElevate || ne
-
-		tmp, ne = getInfoWithElevateWin(
-			[]cmdSpec{
-				{"powershell", []string{"-Command", `Get-WmiObject -Class Win32_BIOS | Select-Object -ExpandProperty SerialNumber`}},
-				{"powershell", []string{"-Command", `Get-ItemPropertyValue -Path 'HKLM:\HARDWARE\DESCRIPTION\System\BIOS' -Name 'BIOSSerialNumber'`}},
-				{"powershell", []string{"-Command", `Get-WmiObject -Namespace 'root\cimv2\mdm\dmmap' -Class 'MSFT_Firmware' | Select-Object -ExpandProperty SerialNumber`}},
-			},
-			1,
-			"info2",
-		)
-		infos[1] = tmp
-		needElevate = needElevate || ne
-
-		tmp, ne = getInfoWithElevateWin(
-			[]cmdSpec{
-				{"powershell", []string{"-Command", "Get-WmiObject -Class Win32_ComputerSystem | Select-Object -ExpandProperty Manufacturer"}},
-			},
-			1,
-			"info3",
-		)
-		infos[2] = tmp
-		needElevate = needElevate || ne
-
-		tmp, ne = getInfoWithElevateWin(
-			[]cmdSpec{
-				{"powershell", []string{"-Command", "Get-WmiObject -Class Win32_ComputerSystem | Select-Object -ExpandProperty Model"}},
-			},
-			1,
-			"info4",
-		)
-		infos[3] = tmp
-		needElevate = needElevate || ne
-
-		tmp, ne = getInfoWithElevateWin(
-			[]cmdSpec{
-				{"powershell", []string{"-Command", `Get-PhysicalDisk | Where-Object {$_.MediaType -ne 'Unspecified'} | Select-Object -First 1 -ExpandProperty SerialNumber`}},
-				{"powershell", []string{"-Command", `Get-WmiObject Win32_DiskDrive | Where-Object {$_.MediaType -like '*Fixed hard disk*'} | Select-Object -First 1 -ExpandProperty SerialNumber`}},
-			},
-			0,
-			"info5",
-		)
-		infos[4] = tmp
-		needElevate = needElevate || ne
-	case "linux":
-		tmp, ne := getInfoWithElevateLinux(
-			[]fileOrCmdSpec{
-				{"file", "/sys/class/dmi/id/product_uuid"},
-				{"cmd", "dmidecode -s system-uuid"},
-			},
-			"info1",
-		)
-		infos[0] = tmp
-		needElevate = needElevate || ne
-
-		tmp, ne = getInfoWithElevateLinux(
-			[]fileOrCmdSpec{
-				{"file", "/sys/class/dmi/id/board_serial"},
-				{"cmd", "dmidecode -s baseboard-serial-number"},
-			},
-			"info2",
-		)
-		infos[1] = tmp
-		needElevate = needElevate || ne
-
-		tmp, ne = getInfoWithElevateLinux(
-			[]fileOrCmdSpec{
-				{"file", "/sys/class/dmi/id/sys_vendor"},
-				{"cmd", "dmidecode -s system-manufacturer"},
-			},
-			"info3",
-		)
-		infos[2] = tmp
-		needElevate = needElevate || ne
-
-		tmp, ne = getInfoWithElevateLinux(
-			[]fileOrCmdSpec{
-				{"file", "/sys/class/dmi/id/product_name"},
-				{"cmd", "dmidecode -s system-product-name"},
-			},
-			"info4",
-		)
-		infos[3] = tmp
-		needElevate = needElevate || ne
-
-		tmp, ne = getInfoWithElevateLinux(
-			[]fileOrCmdSpec{
-				{"file", "/sys/block/sda/serial"},
-				{"file", "/sys/block/nvme0n1/serial"},
-				{"cmd", `lsblk -ndo SERIAL $(lsblk -no pkname $(df / | awk 'NR==2{print $1}')) | head -n 1`},
-			},
-			"info5",
-		)
-		infos[4] = tmp
-		needElevate = needElevate || ne
-	case "darwin":
-		tmp, ne := getInfoWithElevateMac([]string{`system_profiler SPHardwareDataType | grep 'Hardware UUID' | awk '{print $3}'`}, "info1")
-		infos[0] = tmp
-		needElevate = needElevate || ne
-
-		tmp, ne = getInfoWithElevateMac([]string{`system_profiler SPHardwareDataType | grep 'Serial Number (system)' | awk '{print $4}'`}, "info2")
-		infos[1] = tmp
-		needElevate = needElevate || ne
-
-		tmp, ne = getInfoWithElevateMac([]string{`system_profiler SPHardwareDataType | grep 'Model Identifier' | awk '{print $3}'`}, "info3")
-		infos[2] = tmp
-		needElevate = needElevate || ne
+	return GetStandardizedSysInfoV1_1Context(context.Background())
+}
 
-		tmp, ne = getInfoWithElevateMac([]string{`system_profiler SPHardwareDataType | grep 'Model Name' | sed 's/.*: //'`}, "info4")
-		infos[3] = tmp
-		needElevate = needElevate || ne
+// 同 GetStandardizedSysInfoV1_1，采集可通过 ctx 取消；按 auto 策略提权
+func GetStandardizedSysInfoV1_1Context(ctx context.Context) []string {
+	results, report, _ := CollectSysInfoWithPolicy(ctx, ElevationAuto)
+	AppendTestLog("GetStandardizedSysInfoV1_1, elevation outcome: " + string(report.Outcome))
+	return SysInfoValues(results)
+}
 
-		tmp, ne = getInfoWithElevateMac(
-			[]string{
-				`diskutil info disk0 | grep "Serial Number" | awk -F': ' '{print $2}'`,
-				`system_profiler SPNVMeDataType | grep "Serial Number" | awk -F': ' '{print $2}' | head -n 1`,
-				`system_profiler SPSerialATADataType | grep "Serial Number" | awk -F': ' '{print $2}' | head -n 1`,
-			},
-			"info5",
-		)
-		infos[4] = tmp
-		needElevate = needElevate || ne
-	}
+// CollectSysInfoReport 仅执行采集，返回各槽位的详细结果（不提权、不退出），供诊断使用
+func CollectSysInfoReport(ctx context.Context) []SlotResult {
+	return DefaultRegistry.Collect(ctx, runtime.GOOS)
+}
 
-	AppendTestLog("GetStandardizedSysInfoV1_1, needElevate: " + fmt.Sprint(needElevate) + ", alreadyElevated: " + fmt.Sprint(alreadyElevated))
-	if needElevate && !alreadyElevated {
-		AppendTestLog("elevateAndRerunForInfo called")
-		elevateAndRerunForInfo()
-		os.Exit(0)
+// SysInfoValues 提取各槽位的规范化值
+func SysInfoValues(results []SlotResult) []string {
+	infos := make([]string, len(results))
+	for i, r := range results {
+		infos[i] = r.Value
 	}
-
-	return infos[:]
+	return infos
 }
 
-type cmdSpec struct {
-	Name string
-	Args []string
+// V1.1 内置来源，同一槽位内的注册顺序即回退顺序
+func init() {
+	// Windows
+	registerWin(0, 1, "Get-WmiObject -Class Win32_ComputerSystemProduct | Select-Object -ExpandProperty UUID")
+	registerWin(1, 1, `Get-WmiObject -Class Win32_BIOS | Select-Object -ExpandProperty SerialNumber`)
+	registerWin(1, 1, `Get-ItemPropertyValue -Path 'HKLM:\HARDWARE\DESCRIPTION\System\BIOS' -Name 'BIOSSerialNumber'`)
+	registerWin(1, 1, `Get-WmiObject -Namespace 'root\cimv2\mdm\dmmap' -Class 'MSFT_Firmware' | Select-Object -ExpandProperty SerialNumber`)
+	registerWin(2, 1, "Get-WmiObject -Class Win32_ComputerSystem | Select-Object -ExpandProperty Manufacturer")
+	registerWin(3, 1, "Get-WmiObject -Class Win32_ComputerSystem | Select-Object -ExpandProperty Model")
+	registerWin(4, 0, `Get-PhysicalDisk | Where-Object {$_.MediaType -ne 'Unspecified'} | Select-Object -First 1 -ExpandProperty SerialNumber`)
+	registerWin(4, 0, `Get-WmiObject Win32_DiskDrive | Where-Object {$_.MediaType -like '*Fixed hard disk*'} | Select-Object -First 1 -ExpandProperty SerialNumber`)
+
+	// Linux
+	registerLinux(0, fileOrCmdSpec{"file", "/sys/class/dmi/id/product_uuid"})
+	registerLinuxFunc(0, "smbios: system-uuid", smbiosSource("system-uuid"))
+	registerLinux(1, fileOrCmdSpec{"file", "/sys/class/dmi/id/board_serial"})
+	registerLinuxFunc(1, "smbios: baseboard-serial-number", smbiosSource("baseboard-serial-number"))
+	registerLinux(2, fileOrCmdSpec{"file", "/sys/class/dmi/id/sys_vendor"})
+	registerLinuxFunc(2, "smbios: system-manufacturer", smbiosSource("system-manufacturer"))
+	registerLinux(3, fileOrCmdSpec{"file", "/sys/class/dmi/id/product_name"})
+	registerLinuxFunc(3, "smbios: system-product-name", smbiosSource("system-product-name"))
+	registerLinuxFunc(4, "sysfs: 根文件系统所在磁盘", rootDiskSerial)
+	registerLinuxFunc(4, "lsblk: 根文件系统所在磁盘", lsblkRootDiskSerial)
+	registerSBCCollectors()
+
+	// macOS
+	registerMac(0, "system_profiler", []string{"SPHardwareDataType"}, "Hardware UUID", ParseSystemProfilerUUID)
+	registerMac(0, "ioreg", ioregPlatformArgs, "IOPlatformUUID", func(out string) string { return ParseIORegProperty(out, "IOPlatformUUID") })
+	registerMac(1, "system_profiler", []string{"SPHardwareDataType"}, "Serial Number (system)", ParseSystemProfilerSerial)
+	registerMac(1, "ioreg", ioregPlatformArgs, "IOPlatformSerialNumber", func(out string) string { return ParseIORegProperty(out, "IOPlatformSerialNumber") })
+	registerMac(2, "system_profiler", []string{"SPHardwareDataType"}, "Model Identifier", ParseSystemProfilerModelIdentifier)
+	registerMac(2, "ioreg", ioregPlatformArgs, "model", func(out string) string { return ParseIORegProperty(out, "model") })
+	registerMac(3, "system_profiler", []string{"SPHardwareDataType"}, "Model Name", ParseSystemProfilerModelName)
+	registerMac(4, "diskutil", []string{"info", "disk0"}, "Serial Number", func(out string) string { return ParseDiskSerial(out, false) })
+	registerMac(4, "system_profiler", []string{"SPNVMeDataType"}, "Serial Number", func(out string) string { return ParseDiskSerial(out, true) })
+	registerMac(4, "system_profiler", []string{"SPSerialATADataType"}, "Serial Number", func(out string) string { return ParseDiskSerial(out, true) })
 }
 
 type fileOrCmdSpec struct {
@@ -181,158 +88,99 @@ type fileOrCmdSpec struct {
 	Val  string
 }
 
-// Windows: 多方式+提权
-func getInfoWithElevateWin(cmds []cmdSpec, skipHeader int, infoName string) (string, bool) {
-	AppendTestLog("getInfoWithElevateWin for " + infoName)
-	for i, c := range cmds {
-		AppendTestLog(fmt.Sprintf("  attempt %d: %s %v", i+1, c.Name, c.Args))
-		out, err := exec.Command(c.Name, c.Args...).CombinedOutput()
+// ioreg 平台设备查询：system_profiler 超时或不可用时的回退，取值与之相同
+var ioregPlatformArgs = []string{"-rd1", "-c", "IOPlatformExpertDevice"}
+
+// Windows: PowerShell 查询，拒绝访问时要求提权
+func registerWin(slot, skipHeader int, command string) {
+	RegisterCollector(NewCollector("powershell -Command "+command, slot, "windows", false, func(ctx context.Context) (string, error) {
+		out, err := execCombined(ctx, "powershell", "-Command", command)
 		if err != nil {
-			AppendTestLog(fmt.Sprintf("    error: %v, output: %s", err, string(out)))
 			if strings.Contains(strings.ToLower(err.Error()), "access is denied") || strings.Contains(strings.ToLower(err.Error()), "privileges") {
-				return SysInfoUnavailable, true
+				return SysInfoUnavailable, fmt.Errorf("%w: %v", ErrElevationRequired, err)
 			}
-			continue
-		}
-		AppendTestLog(fmt.Sprintf("    success, output: %s", string(out)))
-		id := getAndNormalizeInfo(string(out), skipHeader)
-		if id != SysInfoUnavailable {
-			return id, false
+			return SysInfoUnavailable, fmt.Errorf("%v, output: %s", err, redactOutput(out))
 		}
-	}
-	AppendTestLog("  all attempts failed for " + infoName + ", returning unavailable, no elevation needed from this path")
-	return SysInfoUnavailable, false
+		value := ParsePowerShellValue(string(out), skipHeader)
+		AppendTestLog("    success, output: " + redactInfo(value))
+		return normalizeInfo(value, 0)
+	}))
 }
 
-// Linux: 多方式+提权
-func getInfoWithElevateLinux(specs []fileOrCmdSpec, infoName string) (string, bool) {
-	AppendTestLog("getInfoWithElevateLinux for " + infoName)
-	for i, s := range specs {
-		var out []byte
+// Linux: 优先直接读取 sysfs 文件，命令作为回退（直接执行，不经过 shell）
+func registerLinux(slot int, s fileOrCmdSpec) {
+	RegisterCollector(NewCollector(s.Type+": "+s.Val, slot, "linux", false, func(ctx context.Context) (string, error) {
+		var out string
 		var err error
 		if s.Type == "file" {
-			AppendTestLog(fmt.Sprintf("  attempt %d (file): %s", i+1, s.Val))
-			out, err = exec.Command("cat", s.Val).CombinedOutput()
-		} else if s.Type == "cmd" {
-			AppendTestLog(fmt.Sprintf("  attempt %d (cmd): %s", i+1, s.Val))
-			out, err = exec.Command("bash", "-c", s.Val).CombinedOutput()
+			out, err = readSysFile(s.Val)
+		} else {
+			fields := strings.Fields(s.Val)
+			out, err = runCmd(ctx, fields[0], fields[1:]...)
 		}
 		if err != nil {
-			AppendTestLog(fmt.Sprintf("    error: %v, output: %s", err, string(out)))
-			if strings.Contains(strings.ToLower(err.Error()), "permission denied") {
-				return SysInfoUnavailable, true
-			}
-			continue
-		}
-		AppendTestLog(fmt.Sprintf("    success, output: %s", string(out)))
-		id := getAndNormalizeInfo(string(out), 0)
-		if id != SysInfoUnavailable {
-			return id, false
+			// product_uuid、board_serial 等仅 root 可读，权限不足时按提权策略处理
+			return SysInfoUnavailable, permissionAsElevation(err)
 		}
-	}
-	AppendTestLog("  all attempts failed for " + infoName + ", returning unavailable, no elevation needed from this path")
-	return SysInfoUnavailable, false
+		AppendTestLog("    success, output: " + redactInfo(out))
+		return normalizeInfo(out, 0)
+	}))
+}
+
+// Linux: 以 Go 函数实现的来源
+func registerLinuxFunc(slot int, name string, fn func(ctx context.Context) (string, error)) {
+	RegisterCollector(linuxFuncCollector(slot, name, fn))
 }
 
-// macOS: 多方式+提权
-func getInfoWithElevateMac(cmds []string, infoName string) (string, bool) {
-	AppendTestLog("getInfoWithElevateMac for " + infoName)
-	for i, c := range cmds {
-		AppendTestLog(fmt.Sprintf("  attempt %d: %s", i+1, c))
-		out, err := exec.Command("bash", "-c", c).CombinedOutput()
+// linuxFuncCollector 将返回原始值的函数包装为采集器：失败时返回不可用，成功时规范化并过滤占位值
+func linuxFuncCollector(slot int, name string, fn func(ctx context.Context) (string, error)) Collector {
+	return NewCollector(name, slot, "linux", false, func(ctx context.Context) (string, error) {
+		out, err := fn(ctx)
 		if err != nil {
-			AppendTestLog(fmt.Sprintf("    error: %v, output: %s", err, string(out)))
-			continue
+			return SysInfoUnavailable, err
 		}
-		AppendTestLog(fmt.Sprintf("    success, output: %s", string(out)))
-		id := getAndNormalizeInfo(string(out), 0)
-		if id != SysInfoUnavailable {
-			return id, false
-		}
-	}
-	AppendTestLog("  all attempts failed for " + infoName)
-	return SysInfoUnavailable, true
+		AppendTestLog("    success, output: " + redactInfo(out))
+		return normalizeInfo(out, 0)
+	})
 }
 
-// 自动提权重启自身（与main.go一致，支持HWID场景）
-func elevateAndRerunForInfo() {
-	args := os.Args[1:]
-	newEnv := os.Environ()
-	newEnv = append(newEnv, "ELEVATE_FLAG=1")
-
-	if runtime.GOOS == "windows" {
-		exe, _ := os.Executable()
-		cmdArgs := []string{"-Command", "Start-Process", exe, "-ArgumentList", "'" + strings.Join(args, "' '") + "'", "-Verb", "runAs"}
-		cmd := exec.Command("powershell", cmdArgs...)
-		cmd.Env = newEnv
-		cmd.Stdout = nil
-		cmd.Stderr = nil
-		_ = cmd.Run()
-	} else if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
-		exe, _ := os.Executable()
-		cmdArgs := append([]string{"-E", exe}, args...)
-		cmd := exec.Command("sudo", cmdArgs...)
-		cmd.Env = newEnv
-		cmd.Stdout = nil
-		cmd.Stderr = nil
-		_ = cmd.Run()
-	}
+// macOS: 直接执行探测命令（不经过 shell），由 Go 解析输出；全部失败时要求提权
+func registerMac(slot int, name string, args []string, field string, parse func(string) string) {
+	desc := name + " " + strings.Join(args, " ") + ": " + field
+	RegisterCollector(NewCollector(desc, slot, "darwin", true, func(ctx context.Context) (string, error) {
+		out, err := execOutput(ctx, name, args...)
+		if err != nil {
+			return SysInfoUnavailable, fmt.Errorf("%v, output: %s", err, redactOutput(out))
+		}
+		value := parse(string(out))
+		AppendTestLog("    success, output: " + redactInfo(value))
+		return normalizeInfo(value, 0)
+	}))
 }
 
-// 规范化处理：去首尾空格、转大写、去除- : 空格，空值用占位符
+// 规范化处理：去首尾空格、转大写、去除- : 空格，空值及占位/垃圾值用占位符
 func getAndNormalizeInfo(raw string, skipHeader int) string {
-	if skipHeader > 0 {
-		lines := strings.Split(raw, "\n")
-		for _, line := range lines[skipHeader:] {
-			line = strings.TrimSpace(line)
-			if line != "" {
-				raw = line
-				break
-			}
-		}
-	}
-	raw = strings.TrimSpace(raw)
+	id, _ := normalizeInfo(raw, skipHeader)
+	return id
+}
+
+// normalizeInfo 同 getAndNormalizeInfo，值被判定为占位/垃圾值时返回带原因的 ErrJunkValue
+func normalizeInfo(raw string, skipHeader int) (string, error) {
+	raw = strings.TrimSpace(ParsePowerShellValue(raw, skipHeader))
 	if raw == "" {
-		return SysInfoUnavailable
+		return SysInfoUnavailable, nil
 	}
 	raw = strings.ToUpper(raw)
 	raw = strings.ReplaceAll(raw, "-", "")
 	raw = strings.ReplaceAll(raw, ":", "")
 	raw = strings.ReplaceAll(raw, " ", "")
 	if raw == "" {
-		return SysInfoUnavailable
+		return SysInfoUnavailable, nil
 	}
-	return raw
-}
-
-// 执行命令并返回输出
-func runCmd(name string, args ...string) string {
-	cmd := exec.Command(name, args...)
-	out, err := cmd.Output()
-	if err != nil {
-		return ""
-	}
-	return string(out)
-}
-
-// 读取文件首行
-func readFileLine(path string) string {
-	f, err := exec.Command("cat", path).Output()
-	if err != nil {
-		return ""
+	if reason := junkReason(raw); reason != "" {
+		return SysInfoUnavailable, fmt.Errorf("%w: %s", ErrJunkValue, reason)
 	}
-	s := string(f)
-	return strings.Split(strings.TrimSpace(s), "\n")[0]
-}
-
-// macOS专用命令
-func runMacCmd(cmd string) string {
-	c := exec.Command("bash", "-c", cmd)
-	out, err := c.Output()
-	if err != nil {
-		return ""
-	}
-	return string(bytes.TrimSpace(out))
+	return raw, nil
 }
 
 // 获取最终拼接字符串（顺序1-4，无分隔符）
@@ -343,8 +191,18 @@ func GetFinalConcatenatedSysInfoStringV1_1() string {
 
 // 获取最终派生密钥（HMAC-SHA256）
 func DeriveSysInfoBindKeyV1_1(salt []byte) []byte {
-	finalStr := GetFinalConcatenatedSysInfoStringV1_1()
+	return DeriveSysInfoBindKeyV1_1FromInfos(salt, GetStandardizedSysInfoV1_1())
+}
+
+// 由已采集的信息派生密钥，避免重复采集（旧版派生，KeyScheduleLegacy 下所有用途共用此密钥）
+func DeriveSysInfoBindKeyV1_1FromInfos(salt []byte, infos []string) []byte {
 	mac := hmac.New(sha256.New, salt)
-	mac.Write([]byte(finalStr))
+	mac.Write([]byte(strings.Join(infos, "")))
 	return mac.Sum(nil)
 }
+
+// 绑定密钥指纹（SHA-256 前 8 字节），可安全展示而不泄露密钥本身
+func BindKeyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
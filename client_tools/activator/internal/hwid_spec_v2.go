@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// * 系统信息规范 V2
+// * V1.1 直接拼接五项值（无分隔符），"AB"+"C" 与 "A"+"BC" 会得到同一绑定密钥。
+// * V2 采用 TLV 规范编码：tag(1 字节) | length(2 字节大端) | value，
+// * 首项为规范版本（tag 0x00），随后为五个槽位（tag 0x01 ~ 0x05，顺序固定），整体作为 HKDF 的 IKM（见 NewKeySchedule）。
+// * 实际使用的规范由密钥编排版本决定（见 KeyScheduleVersion.SysInfoSpec），并随 PlatformInfo 的 SysInfoSpec 上报。
+
+const SysInfoSpecVersionV2 = "V2"
+
+const (
+	sysInfoTagVersion byte = 0x00
+	// 槽位 tag = 槽位序号 + 1（info1 -> 0x01 ... info5 -> 0x05）
+	sysInfoTagSlotBase byte = 0x01
+)
+
+// EncodeSysInfoV2 将五项规范化信息编码为 V2 规范字节串
+func EncodeSysInfoV2(infos []string) ([]byte, error) {
+	if len(infos) != SysInfoSlotCount {
+		return nil, fmt.Errorf("系统信息项数错误: %d", len(infos))
+	}
+	buf := appendSysInfoTLV(nil, sysInfoTagVersion, SysInfoSpecVersionV2)
+	for i, v := range infos {
+		if len(v) > 0xFFFF {
+			return nil, fmt.Errorf("%s 过长: %d 字节", slotName(i), len(v))
+		}
+		buf = appendSysInfoTLV(buf, sysInfoTagSlotBase+byte(i), v)
+	}
+	return buf, nil
+}
+
+func appendSysInfoTLV(buf []byte, tag byte, v string) []byte {
+	buf = append(buf, tag)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(v)))
+	return append(buf, v...)
+}
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSysInfoSpecV2Unambiguous(t *testing.T) {
+	salt := []byte("salt")
+	a := []string{"AB", "C", "X", "Y", "Z"}
+	b := []string{"A", "BC", "X", "Y", "Z"}
+
+	// V1.1 直接拼接：两组信息得到同一密钥
+	if !bytes.Equal(DeriveSysInfoBindKeyV1_1FromInfos(salt, a), DeriveSysInfoBindKeyV1_1FromInfos(salt, b)) {
+		t.Fatal("V1.1 拼接预期存在歧义")
+	}
+	ka, err := NewKeySchedule(KeyScheduleHKDFV1, salt, "", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb, err := NewKeySchedule(KeyScheduleHKDFV1, salt, "", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(ka.secret, kb.secret) {
+		t.Fatal("V2 编码不应产生相同密钥")
+	}
+	if _, err := EncodeSysInfoV2(a[:4]); err == nil {
+		t.Fatal("项数错误应失败")
+	}
+	if _, err := NewKeySchedule(KeyScheduleHKDFV1, salt, "", a[:4]); err == nil {
+		t.Fatal("项数错误时不应建立编排")
+	}
+}
+
+func TestKeyScheduleSysInfoSpec(t *testing.T) {
+	if got := KeyScheduleLegacy.SysInfoSpec(); got != SysInfoSpecVersion {
+		t.Fatalf("legacy: %s", got)
+	}
+	if got := KeyScheduleHKDFV1.SysInfoSpec(); got != SysInfoSpecVersionV2 {
+		t.Fatalf("hkdf: %s", got)
+	}
+}
@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// * 会话脚本密钥（SSK）的密钥协商
+// * x25519-hkdf-sha256-aes256gcm：客户端发送临时 X25519 公钥，服务端以自己的临时密钥完成 ECDH，
+// *   wrapKey = HKDF-SHA256(IKM = 共享密钥, salt = 客户端公钥 | 服务端公钥, info = 标签 | 0x00 | 算法标识)
+// *   encrypted_session_script_key = nonce(12) | AES-256-GCM(wrapKey, SSK, AAD = 算法标识)
+// * mlkem768-x25519-hkdf-sha256-aes256gcm：在 X25519 之上叠加 ML-KEM-768 的后量子混合模式，见 key_agreement_hybrid.go
+// * rsa-oaep-sha256：原有方式，客户端发送 RSA-4096 公钥，服务端以 OAEP-SHA256 加密 SSK；保留给旧服务端。
+// * 算法经 /api/activation-challenge 协商：客户端按优先顺序声明支持的算法，服务端回传所选算法，
+// * 旧服务端不回传时即 rsa-oaep-sha256；激活响应的 key_agreement 标明实际包装 SSK 所用的算法。
+// * 防降级：编译时固定可接受的最低算法（默认 x25519-hkdf-sha256-aes256gcm），客户端只声明不弱于它的算法，
+// * 服务端所选、旧服务端隐含的 RSA 或响应中的算法弱于最低算法时一律拒绝，中间人删去 key_agreement 无法退回 RSA。
+
+// 密钥协商算法标识
+const (
+	KeyAgreementX25519  = "x25519-hkdf-sha256-aes256gcm"
+	KeyAgreementRSAOAEP = "rsa-oaep-sha256"
+)
+
+// SupportedKeyAgreements 客户端支持的算法，按优先顺序（同时也是强弱顺序，越靠前越强）
+var SupportedKeyAgreements = []string{KeyAgreementHybrid, KeyAgreementX25519, KeyAgreementRSAOAEP}
+
+// DefaultMinKeyAgreement 编译时未指定最低算法时的默认值：默认构建中 RSA-OAEP 回退与旧服务端（挑战接口 404）均不可用，
+// 需兼容旧服务端的构建须显式指定 rsa-oaep-sha256
+const DefaultMinKeyAgreement = KeyAgreementX25519
+
+const sskWrapLabel = "activator/v1 ssk-wrap"
+
+// ParseMinKeyAgreement 解析编译时注入的最低密钥协商算法，留空时为 DefaultMinKeyAgreement
+func ParseMinKeyAgreement(s string) (string, error) {
+	if s == "" {
+		return DefaultMinKeyAgreement, nil
+	}
+	if !slices.Contains(SupportedKeyAgreements, s) {
+		return "", fmt.Errorf("未知的最低密钥协商算法: %s（支持 %s）", s, strings.Join(SupportedKeyAgreements, ", "))
+	}
+	return s, nil
+}
+
+// AcceptableKeyAgreements 返回不弱于 minimum 的算法，按优先顺序；用于挑战请求中的声明
+func AcceptableKeyAgreements(minimum string) []string {
+	i := slices.Index(SupportedKeyAgreements, minimum)
+	if i < 0 {
+		return nil
+	}
+	return SupportedKeyAgreements[:i+1]
+}
+
+// NegotiateKeyAgreement 校验服务端所选算法；未回传（旧服务端）时视为 rsa-oaep-sha256，
+// 弱于 minimum 的算法视为降级并拒绝
+func NegotiateKeyAgreement(selected, minimum string) (string, error) {
+	alg := selected
+	if alg == "" {
+		alg = KeyAgreementRSAOAEP
+	}
+	if !slices.Contains(SupportedKeyAgreements, alg) {
+		return "", fmt.Errorf("服务端选择了不支持的密钥协商算法: %s（支持 %s）", selected, strings.Join(SupportedKeyAgreements, ", "))
+	}
+	if !slices.Contains(AcceptableKeyAgreements(minimum), alg) {
+		return "", fmt.Errorf("密钥协商算法 %s 弱于本构建要求的最低算法 %s，拒绝降级", alg, minimum)
+	}
+	return alg, nil
+}
+
+// SessionKeyUnwrapper 客户端一侧的密钥协商：提供请求中的公钥并解包服务端返回的 SSK
+type SessionKeyUnwrapper interface {
+	// Algorithm 返回算法标识
+	Algorithm() string
+	// PublicKey 返回写入 client_public_key 的 base64 字符串
+	PublicKey() (string, error)
+	// UnwrapSessionKey 解包 encrypted_session_script_key；serverPublicKey 仅 ECDH 模式使用
+	UnwrapSessionKey(wrapped, serverPublicKey []byte) ([]byte, error)
+	// Destroy 擦除私钥材料，之后不可再使用；ecdh / mlkem 私钥为不透明类型，只能丢弃引用
+	Destroy()
+}
+
+// x25519Unwrapper 临时 X25519 密钥，每次激活重新生成
+type x25519Unwrapper struct {
+	priv *ecdh.PrivateKey
+}
+
+// NewX25519Unwrapper 生成临时 X25519 密钥对
+func NewX25519Unwrapper() (SessionKeyUnwrapper, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &x25519Unwrapper{priv: priv}, nil
+}
+
+func (u *x25519Unwrapper) Algorithm() string { return KeyAgreementX25519 }
+
+func (u *x25519Unwrapper) PublicKey() (string, error) {
+	return base64.StdEncoding.EncodeToString(u.priv.PublicKey().Bytes()), nil
+}
+
+func (u *x25519Unwrapper) UnwrapSessionKey(wrapped, serverPublicKey []byte) ([]byte, error) {
+	serverPub, err := ecdh.X25519().NewPublicKey(serverPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("服务端公钥无效: %w", err)
+	}
+	shared, err := u.priv.ECDH(serverPub)
+	if err != nil {
+		return nil, err
+	}
+	defer WipeBytes(shared)
+	wrapKey, err := deriveSSKWrapKey(shared, u.priv.PublicKey().Bytes(), serverPublicKey, KeyAgreementX25519)
+	if err != nil {
+		return nil, err
+	}
+	defer WipeBytes(wrapKey)
+	return openWrappedSSK(wrapKey, wrapped, KeyAgreementX25519)
+}
+
+func (u *x25519Unwrapper) Destroy() { u.priv = nil }
+
+// rsaUnwrapper 原有 RSA-OAEP 方式
+type rsaUnwrapper struct {
+	priv *rsa.PrivateKey
+}
+
+// NewRSAUnwrapper 以已有 RSA 私钥构造（私钥可能来自本地密封状态）；私钥登记为退出时擦除
+func NewRSAUnwrapper(priv *rsa.PrivateKey) SessionKeyUnwrapper {
+	u := &rsaUnwrapper{priv: priv}
+	registerWiper(u)
+	return u
+}
+
+func (u *rsaUnwrapper) Algorithm() string { return KeyAgreementRSAOAEP }
+
+func (u *rsaUnwrapper) PublicKey() (string, error) {
+	pubKeyPEM, err := EncodePublicKeyToPEM(&u.priv.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(pubKeyPEM), nil
+}
+
+func (u *rsaUnwrapper) UnwrapSessionKey(wrapped, _ []byte) ([]byte, error) {
+	return u.priv.Decrypt(nil, wrapped, &rsa.OAEPOptions{Hash: crypto.SHA256, Label: nil})
+}
+
+func (u *rsaUnwrapper) Destroy() {
+	unregisterWiper(u)
+	u.wipe()
+	u.priv = nil
+}
+
+func (u *rsaUnwrapper) wipe() { WipeRSAPrivateKey(u.priv) }
+
+// WrapSessionKeyX25519 服务端一侧的参考实现：以临时 X25519 密钥包装 SSK，返回服务端公钥与包装结果
+func WrapSessionKeyX25519(clientPublicKey, ssk []byte) (serverPublicKey, wrapped []byte, err error) {
+	clientPub, err := ecdh.X25519().NewPublicKey(clientPublicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("客户端公钥无效: %w", err)
+	}
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	shared, err := priv.ECDH(clientPub)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer WipeBytes(shared)
+	serverPublicKey = priv.PublicKey().Bytes()
+	wrapKey, err := deriveSSKWrapKey(shared, clientPublicKey, serverPublicKey, KeyAgreementX25519)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer WipeBytes(wrapKey)
+	nonce, ct, err := aesGCMSeal(wrapKey, ssk, []byte(KeyAgreementX25519))
+	if err != nil {
+		return nil, nil, err
+	}
+	return serverPublicKey, append(nonce, ct...), nil
+}
+
+// deriveSSKWrapKey wrapKey = HKDF-SHA256(shared, salt = clientPub | serverPub, info = 标签 | 0x00 | 算法标识)
+func deriveSSKWrapKey(shared, clientPub, serverPub []byte, alg string) ([]byte, error) {
+	salt := append(append([]byte(nil), clientPub...), serverPub...)
+	return hkdf.Key(sha256.New, shared, salt, sskWrapLabel+"\x00"+alg, DerivedKeySize)
+}
+
+// openWrappedSSK 解开 nonce(12) | AES-256-GCM 密文，AAD 为算法标识
+func openWrappedSSK(wrapKey, wrapped []byte, alg string) ([]byte, error) {
+	block, err := aes.NewCipher(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("包装后的会话密钥过短")
+	}
+	return aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(alg))
+}
+
+// aesGCMSeal 同 AESGCMEncrypt，附带 AAD
+func aesGCMSeal(key, plaintext, aad []byte) ([]byte, []byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, aead.Seal(nil, nonce, plaintext, aad), nil
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"crypto/ecdh"
+	"crypto/mlkem"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// * 后量子混合密钥协商：ML-KEM-768 + X25519
+// * 客户端公钥 = X25519 公钥(32) | ML-KEM-768 封装密钥(1184)
+// * 服务端公钥 = X25519 临时公钥(32) | ML-KEM-768 密文(1088)
+// * wrapKey = HKDF-SHA256(IKM = ML-KEM 共享密钥 | X25519 共享密钥, salt = 客户端公钥 | 服务端公钥, info = 标签 | 0x00 | 算法标识)
+// * 两个共享密钥同时进入 HKDF，任一算法未被攻破，wrapKey 即保持安全；
+// * 即便现在截获流量、日后以量子计算机破解 X25519，也无法还原 SSK。
+
+// KeyAgreementHybrid 混合模式算法标识
+const KeyAgreementHybrid = "mlkem768-x25519-hkdf-sha256-aes256gcm"
+
+const (
+	x25519PublicKeySize = 32
+	hybridClientKeySize = x25519PublicKeySize + mlkem.EncapsulationKeySize768
+	hybridServerKeySize = x25519PublicKeySize + mlkem.CiphertextSize768
+)
+
+// hybridUnwrapper 临时 X25519 与 ML-KEM-768 密钥，每次激活重新生成
+type hybridUnwrapper struct {
+	x25519 *ecdh.PrivateKey
+	mlkem  *mlkem.DecapsulationKey768
+}
+
+// NewHybridUnwrapper 生成临时 X25519 与 ML-KEM-768 密钥对
+func NewHybridUnwrapper() (SessionKeyUnwrapper, error) {
+	x, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	dk, err := mlkem.GenerateKey768()
+	if err != nil {
+		return nil, err
+	}
+	return &hybridUnwrapper{x25519: x, mlkem: dk}, nil
+}
+
+func (u *hybridUnwrapper) Algorithm() string { return KeyAgreementHybrid }
+
+func (u *hybridUnwrapper) publicKeyBytes() []byte {
+	out := make([]byte, 0, hybridClientKeySize)
+	out = append(out, u.x25519.PublicKey().Bytes()...)
+	return append(out, u.mlkem.EncapsulationKey().Bytes()...)
+}
+
+func (u *hybridUnwrapper) PublicKey() (string, error) {
+	return base64.StdEncoding.EncodeToString(u.publicKeyBytes()), nil
+}
+
+func (u *hybridUnwrapper) UnwrapSessionKey(wrapped, serverPublicKey []byte) ([]byte, error) {
+	wrapKey, err := u.wrapKey(serverPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	defer WipeBytes(wrapKey)
+	return openWrappedSSK(wrapKey, wrapped, KeyAgreementHybrid)
+}
+
+func (u *hybridUnwrapper) Destroy() { u.x25519, u.mlkem = nil, nil }
+
+// wrapKey 由服务端公钥（X25519 公钥 | ML-KEM 密文）计算 wrapKey
+func (u *hybridUnwrapper) wrapKey(serverPublicKey []byte) ([]byte, error) {
+	if len(serverPublicKey) != hybridServerKeySize {
+		return nil, fmt.Errorf("服务端公钥长度无效: %d", len(serverPublicKey))
+	}
+	serverPub, err := ecdh.X25519().NewPublicKey(serverPublicKey[:x25519PublicKeySize])
+	if err != nil {
+		return nil, fmt.Errorf("服务端公钥无效: %w", err)
+	}
+	ecdhShared, err := u.x25519.ECDH(serverPub)
+	if err != nil {
+		return nil, err
+	}
+	defer WipeBytes(ecdhShared)
+	kemShared, err := u.mlkem.Decapsulate(serverPublicKey[x25519PublicKeySize:])
+	if err != nil {
+		return nil, err
+	}
+	ikm := hybridIKM(kemShared, ecdhShared)
+	defer ikm.Destroy()
+	return deriveSSKWrapKey(ikm.Bytes(), u.publicKeyBytes(), serverPublicKey, KeyAgreementHybrid)
+}
+
+// hybridIKM 拼接 ML-KEM 共享密钥与 X25519 共享密钥；结果放在锁定内存中，两个来源随即擦除
+func hybridIKM(kemShared, ecdhShared []byte) *SecretBuffer {
+	ikm := NewSecretBuffer(len(kemShared) + len(ecdhShared))
+	n := copy(ikm.Bytes(), kemShared)
+	copy(ikm.Bytes()[n:], ecdhShared)
+	WipeBytes(kemShared)
+	WipeBytes(ecdhShared)
+	return ikm
+}
+
+// WrapSessionKeyHybrid 服务端一侧的参考实现：封装 ML-KEM 共享密钥并以临时 X25519 密钥完成 ECDH，返回服务端公钥与包装结果
+func WrapSessionKeyHybrid(clientPublicKey, ssk []byte) (serverPublicKey, wrapped []byte, err error) {
+	if len(clientPublicKey) != hybridClientKeySize {
+		return nil, nil, fmt.Errorf("客户端公钥长度无效: %d", len(clientPublicKey))
+	}
+	clientX, err := ecdh.X25519().NewPublicKey(clientPublicKey[:x25519PublicKeySize])
+	if err != nil {
+		return nil, nil, fmt.Errorf("客户端公钥无效: %w", err)
+	}
+	ek, err := mlkem.NewEncapsulationKey768(clientPublicKey[x25519PublicKeySize:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("客户端公钥无效: %w", err)
+	}
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	ecdhShared, err := priv.ECDH(clientX)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer WipeBytes(ecdhShared)
+	kemShared, ciphertext := ek.Encapsulate()
+	ikm := hybridIKM(kemShared, ecdhShared)
+	defer ikm.Destroy()
+
+	serverPublicKey = append(priv.PublicKey().Bytes(), ciphertext...)
+	wrapKey, err := deriveSSKWrapKey(ikm.Bytes(), clientPublicKey, serverPublicKey, KeyAgreementHybrid)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer WipeBytes(wrapKey)
+	nonce, ct, err := aesGCMSeal(wrapKey, ssk, []byte(KeyAgreementHybrid))
+	if err != nil {
+		return nil, nil, err
+	}
+	return serverPublicKey, append(nonce, ct...), nil
+}
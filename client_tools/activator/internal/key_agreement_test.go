@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/mlkem"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+// testdata/vectors/hybrid_ssk_wrap.json 为混合模式 SSK 包装向量（均为十六进制）。
+// 客户端私钥（X25519 私钥、ML-KEM-768 种子 d||z）与服务端输出固定，其他实现可据此复现：
+//
+//	客户端公钥 = X25519(client_x25519_private) | ML-KEM-768 封装密钥(client_mlkem768_seed)
+//	服务端公钥 = server_x25519_public | mlkem768_ciphertext
+//	wrap_key 按 key_agreement_hybrid.go 的组合方式计算，wrapped_ssk 解开后应得到 ssk
+type hybridWrapVector struct {
+	ClientX25519Private string `json:"client_x25519_private"`
+	ClientMLKEMSeed     string `json:"client_mlkem768_seed"`
+	ServerX25519Public  string `json:"server_x25519_public"`
+	MLKEMCiphertext     string `json:"mlkem768_ciphertext"`
+	WrapKey             string `json:"wrap_key"`
+	WrappedSSK          string `json:"wrapped_ssk"`
+	SSK                 string `json:"ssk"`
+}
+
+func TestHybridWrapVectors(t *testing.T) {
+	for i, v := range loadVectors[hybridWrapVector](t, "hybrid_ssk_wrap.json") {
+		x, err := ecdh.X25519().NewPrivateKey(mustHex(t, v.ClientX25519Private))
+		if err != nil {
+			t.Fatalf("向量 %d: %v", i, err)
+		}
+		dk, err := mlkem.NewDecapsulationKey768(mustHex(t, v.ClientMLKEMSeed))
+		if err != nil {
+			t.Fatalf("向量 %d: %v", i, err)
+		}
+		u := &hybridUnwrapper{x25519: x, mlkem: dk}
+		serverPublicKey := append(mustHex(t, v.ServerX25519Public), mustHex(t, v.MLKEMCiphertext)...)
+		wrapKey, err := u.wrapKey(serverPublicKey)
+		if err != nil {
+			t.Fatalf("向量 %d: %v", i, err)
+		}
+		if got := hex.EncodeToString(wrapKey); got != v.WrapKey {
+			t.Errorf("向量 %d wrap_key: 期望 %s, 得到 %s", i, v.WrapKey, got)
+		}
+		ssk, err := u.UnwrapSessionKey(mustHex(t, v.WrappedSSK), serverPublicKey)
+		if err != nil {
+			t.Fatalf("向量 %d: %v", i, err)
+		}
+		if !bytes.Equal(ssk, mustHex(t, v.SSK)) {
+			t.Errorf("向量 %d ssk: 期望 %s, 得到 %x", i, v.SSK, ssk)
+		}
+	}
+}
+
+// 拼接结果为 ML-KEM 共享密钥 | X25519 共享密钥，两个来源均被擦除
+func TestHybridIKMWipesSources(t *testing.T) {
+	kemShared := bytes.Repeat([]byte{0x11}, 32)
+	ecdhShared := bytes.Repeat([]byte{0x22}, 32)
+	want := append(bytes.Clone(kemShared), ecdhShared...)
+	ikm := hybridIKM(kemShared, ecdhShared)
+	defer ikm.Destroy()
+	if !bytes.Equal(ikm.Bytes(), want) {
+		t.Fatalf("IKM: 期望 %x, 得到 %x", want, ikm.Bytes())
+	}
+	if !bytes.Equal(kemShared, make([]byte, 32)) || !bytes.Equal(ecdhShared, make([]byte, 32)) {
+		t.Fatal("共享密钥未擦除")
+	}
+}
+
+func TestNegotiateKeyAgreement(t *testing.T) {
+	tests := []struct {
+		selected, minimum string
+		want              string
+		wantErr           bool
+	}{
+		{KeyAgreementHybrid, DefaultMinKeyAgreement, KeyAgreementHybrid, false},
+		{KeyAgreementX25519, DefaultMinKeyAgreement, KeyAgreementX25519, false},
+		{KeyAgreementRSAOAEP, DefaultMinKeyAgreement, "", true},
+		{"", DefaultMinKeyAgreement, "", true},
+		{KeyAgreementX25519, KeyAgreementHybrid, "", true},
+		{"", KeyAgreementRSAOAEP, KeyAgreementRSAOAEP, false},
+		{"dh-1024", KeyAgreementRSAOAEP, "", true},
+	}
+	for _, tt := range tests {
+		got, err := NegotiateKeyAgreement(tt.selected, tt.minimum)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NegotiateKeyAgreement(%q, %q) = %q, %v", tt.selected, tt.minimum, got, err)
+		}
+	}
+	if _, err := ParseMinKeyAgreement("rsa"); err == nil {
+		t.Error("未知的最低算法应报错")
+	}
+	if m, _ := ParseMinKeyAgreement(""); m != DefaultMinKeyAgreement {
+		t.Errorf("默认最低算法: %q", m)
+	}
+}
+
+// 以下基准对比各密钥协商在客户端一侧的耗时：生成密钥 + 解包 SSK（服务端包装不计时）。
+// go test -bench Unwrap ./internal
+
+func benchmarkSSK(b *testing.B) []byte {
+	ssk := make([]byte, 32)
+	if _, err := rand.Read(ssk); err != nil {
+		b.Fatal(err)
+	}
+	return ssk
+}
+
+func BenchmarkUnwrapRSA4096(b *testing.B) {
+	ssk := benchmarkSSK(b)
+	for i := 0; i < b.N; i++ {
+		priv, err := GenerateRSAKeyPair()
+		if err != nil {
+			b.Fatal(err)
+		}
+		u := NewRSAUnwrapper(priv)
+		b.StopTimer()
+		wrapped, err := rsa.EncryptOAEP(crypto.SHA256.New(), rand.Reader, &priv.PublicKey, ssk, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		got, err := u.UnwrapSessionKey(wrapped, nil)
+		if err != nil || !bytes.Equal(got, ssk) {
+			b.Fatal("解包结果不一致", err)
+		}
+		u.Destroy()
+	}
+}
+
+func BenchmarkUnwrapX25519(b *testing.B) {
+	benchmarkUnwrapEphemeral(b, NewX25519Unwrapper, WrapSessionKeyX25519)
+}
+
+func BenchmarkUnwrapHybrid(b *testing.B) {
+	benchmarkUnwrapEphemeral(b, NewHybridUnwrapper, WrapSessionKeyHybrid)
+}
+
+// benchmarkUnwrapEphemeral 临时密钥模式（X25519、混合模式）：newUnwrapper 为客户端一侧，wrap 为服务端参考实现（不计时）
+func benchmarkUnwrapEphemeral(b *testing.B, newUnwrapper func() (SessionKeyUnwrapper, error),
+	wrap func(clientPublicKey, ssk []byte) ([]byte, []byte, error)) {
+	ssk := benchmarkSSK(b)
+	for i := 0; i < b.N; i++ {
+		u, err := newUnwrapper()
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		pub, _ := u.PublicKey()
+		clientPub, _ := base64.StdEncoding.DecodeString(pub)
+		serverPub, wrapped, err := wrap(clientPub, ssk)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		got, err := u.UnwrapSessionKey(wrapped, serverPub)
+		if err != nil || !bytes.Equal(got, ssk) {
+			b.Fatal("解包结果不一致", err)
+		}
+		u.Destroy()
+	}
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// * 密钥编排（key schedule）
+// * 旧版以构建盐值为 HMAC 密钥一次性派生，同一输出用于所有用途；
+// * hkdf-sha256-v1 先以构建盐值 HKDF-Extract 硬件信息得到 PRK，再按用途标签与盐值代号 HKDF-Expand：
+// *   IKM  = EncodeSysInfoV2(infos)
+// *   PRK  = HKDF-Extract(SHA-256, salt = 构建盐值, IKM)
+// *   key  = HKDF-Expand(SHA-256, PRK, info = 用途标签 | 0x00 | 盐值代号, 32)
+// * 盐值代号标识构建盐值的轮换批次，轮换盐值时随之更换，不同批次的密钥互不相同。
+
+// KeyScheduleVersion 密钥编排版本
+type KeyScheduleVersion string
+
+const (
+	// KeyScheduleLegacy 旧版：DeriveSysInfoBindKeyV1_1 / DeriveKeyFromHWIDs，所有用途共用一个密钥
+	KeyScheduleLegacy KeyScheduleVersion = "legacy-hmac"
+	// KeyScheduleHKDFV1 按用途分离的 HKDF-SHA256 派生
+	KeyScheduleHKDFV1 KeyScheduleVersion = "hkdf-sha256-v1"
+)
+
+// SysInfoSpec 该编排绑定硬件信息所用的系统信息规范：旧版为 V1.1 拼接，hkdf-sha256-v1 为 V2 TLV 编码
+func (v KeyScheduleVersion) SysInfoSpec() string {
+	if v == KeyScheduleLegacy {
+		return SysInfoSpecVersion
+	}
+	return SysInfoSpecVersionV2
+}
+
+// DefaultKeySchedule 未指定时使用的编排版本
+const DefaultKeySchedule = KeyScheduleHKDFV1
+
+// DefaultSaltGeneration 构建时未注入盐值代号时使用的代号
+const DefaultSaltGeneration = "0"
+
+// DerivedKeySize 派生密钥长度（AES-256）
+const DerivedKeySize = 32
+
+// KeyPurpose 密钥用途标签，作为 HKDF info 的前缀
+type KeyPurpose string
+
+const (
+	KeyPurposeStateEncryption KeyPurpose = "activator/v1 state-encryption"
+	KeyPurposeRequestAuth     KeyPurpose = "activator/v1 request-authentication"
+	KeyPurposeCacheSealing    KeyPurpose = "activator/v1 cache-sealing"
+)
+
+// ParseKeyScheduleVersion 解析编排版本，空字符串视为 DefaultKeySchedule
+func ParseKeyScheduleVersion(s string) (KeyScheduleVersion, error) {
+	switch v := KeyScheduleVersion(strings.TrimSpace(s)); v {
+	case "":
+		return DefaultKeySchedule, nil
+	case KeyScheduleLegacy, KeyScheduleHKDFV1:
+		return v, nil
+	default:
+		return "", fmt.Errorf("不支持的密钥编排版本: %q", s)
+	}
+}
+
+// KeySchedule 由构建盐值与硬件信息建立的密钥编排
+type KeySchedule struct {
+	Version    KeyScheduleVersion
+	Generation string
+	secret     []byte // legacy: 旧版绑定密钥；hkdf: PRK
+}
+
+// NewKeySchedule 建立密钥编排；generation 为空时使用 DefaultSaltGeneration
+func NewKeySchedule(version KeyScheduleVersion, salt []byte, generation string, infos []string) (*KeySchedule, error) {
+	if generation == "" {
+		generation = DefaultSaltGeneration
+	}
+	if strings.ContainsRune(generation, 0) {
+		return nil, fmt.Errorf("盐值代号无效: %q", generation)
+	}
+	ks := &KeySchedule{Version: version, Generation: generation}
+	switch version {
+	case KeyScheduleLegacy:
+		ks.secret = DeriveSysInfoBindKeyV1_1FromInfos(salt, infos)
+	case KeyScheduleHKDFV1:
+		ikm, err := EncodeSysInfoV2(infos)
+		if err != nil {
+			return nil, err
+		}
+		if ks.secret, err = hkdf.Extract(sha256.New, ikm, salt); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("不支持的密钥编排版本: %q", version)
+	}
+	return ks, nil
+}
+
+// Derive 派生指定用途的 32 字节密钥；旧版编排对所有用途返回同一密钥
+func (ks *KeySchedule) Derive(purpose KeyPurpose) ([]byte, error) {
+	if ks.Version == KeyScheduleLegacy {
+		return append([]byte(nil), ks.secret...), nil
+	}
+	return hkdf.Expand(sha256.New, ks.secret, keyScheduleInfo(purpose, ks.Generation), DerivedKeySize)
+}
+
+// Wipe 擦除编排内部的秘密材料
+func (ks *KeySchedule) Wipe() {
+	WipeBytes(ks.secret)
+}
+
+// keyScheduleInfo info = 用途标签 | 0x00 | 盐值代号
+func keyScheduleInfo(purpose KeyPurpose, generation string) string {
+	return string(purpose) + "\x00" + generation
+}
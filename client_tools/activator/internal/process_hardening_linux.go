@@ -0,0 +1,28 @@
+//go:build linux
+
+package internal
+
+import (
+	"errors"
+	"syscall"
+)
+
+// madvDontdump MADV_DONTDUMP（syscall 包未导出）
+const madvDontdump = 16
+
+// HardenProcess 禁止核心转储并将进程标记为不可 dump（PR_SET_DUMPABLE=0 同时阻止同用户进程 ptrace 与读取 /proc/self/mem）
+func HardenProcess() error {
+	var errs []error
+	if _, _, e := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_DUMPABLE, 0, 0); e != 0 {
+		errs = append(errs, e)
+	}
+	if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &syscall.Rlimit{}); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// excludeFromCoreDump 即使核心转储被重新启用，这些页也不会写入转储
+func excludeFromCoreDump(mem []byte) {
+	_ = syscall.Madvise(mem, madvDontdump)
+}
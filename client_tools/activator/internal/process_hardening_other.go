@@ -0,0 +1,8 @@
+//go:build !unix
+
+package internal
+
+// HardenProcess 当前平台无可用的进程级加固
+func HardenProcess() error {
+	return nil
+}
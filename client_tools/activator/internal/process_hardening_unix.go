@@ -0,0 +1,12 @@
+//go:build unix && !linux
+
+package internal
+
+import "syscall"
+
+// HardenProcess 禁止核心转储（RLIMIT_CORE=0）
+func HardenProcess() error {
+	return syscall.Setrlimit(syscall.RLIMIT_CORE, &syscall.Rlimit{})
+}
+
+func excludeFromCoreDump([]byte) {}
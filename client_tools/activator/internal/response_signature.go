@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// * 挑战与激活响应签名
+// * 服务端以 Ed25519 私钥对规范化的响应签名（分离签名，放在 signature 字段），
+// * 客户端以编译时注入的公钥验证，验证通过前不协商、不解密、不执行任何内容。
+// *
+// * 规范化格式: 域标签，随后每个字段依次为 uint32 BE 长度 | 字段字节（字符串按响应中原样，不做 base64 解码）。
+// * 挑战响应:
+// *   install_session_token | 客户端声明的算法个数(uint32 BE) | 各算法 | challenge_salt | server_nonce | key_agreement
+// *   客户端声明的算法列表进入签名，中间人删改 key_agreements 或 key_agreement 都会使验证失败。
+// * 激活响应:
+// *   client_public_key | install_session_token | 挑战盐值 | 服务端随机数（二者为解码后的原始字节） |
+// *   key_agreement | server_public_key | encrypted_session_script_key | encrypted_script_blob |
+// *   launcher_download_url | interpreter | 参数个数(uint32 BE) | 各参数 | type_hint | encrypted_payload_url |
+// *   script_aad_version
+// *   script_aad_version 进入签名，中间人无法改选客户端同样支持的其他 AAD 版本。
+// *   服务端每次挑战下发新的随机数：复用的 RSA 私钥使 client_public_key 在重试间不变，
+// *   旧的已签名响应因随机数不同而无法重放。旧服务端（无挑战接口）两者为空，不具备该保护。
+
+const (
+	responseSignatureDomain  = "activator/v1 activate-machine-response"
+	challengeSignatureDomain = "activator/v1 activation-challenge"
+)
+
+// ErrResponseSignature 响应签名缺失或无效
+var ErrResponseSignature = errors.New("服务端响应签名验证失败")
+
+// ParseServerVerifyKey 解析 base64 编码的 32 字节 Ed25519 公钥
+func ParseServerVerifyKey(s string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("服务端验证公钥无效: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("服务端验证公钥长度无效: %d", len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// ResponseBinding 激活响应签名绑定的本次请求：发送的 client_public_key、令牌与所用挑战
+type ResponseBinding struct {
+	ClientPublicKey     string
+	InstallSessionToken string
+	ChallengeSalt       []byte
+	ServerNonce         []byte
+}
+
+// Binding 返回本次挑战对应的响应绑定
+func (c *ActivationChallenge) Binding(clientPublicKey, installSessionToken string) ResponseBinding {
+	return ResponseBinding{
+		ClientPublicKey:     clientPublicKey,
+		InstallSessionToken: installSessionToken,
+		ChallengeSalt:       c.Salt,
+		ServerNonce:         c.ServerNonce,
+	}
+}
+
+// CanonicalActivateResponse 返回待签名的规范化响应
+func CanonicalActivateResponse(resp *ActivateMachineResponse, binding ResponseBinding) []byte {
+	meta := resp.ScriptExecutionMetadata
+	buf := []byte(responseSignatureDomain)
+	for _, f := range []string{
+		binding.ClientPublicKey,
+		binding.InstallSessionToken,
+		string(binding.ChallengeSalt),
+		string(binding.ServerNonce),
+		resp.KeyAgreement,
+		resp.ServerPublicKey,
+		resp.EncryptedSessionScriptKey,
+		resp.EncryptedScriptBlob,
+		resp.LauncherDownloadURL,
+		meta.Interpreter,
+	} {
+		buf = appendSignedField(buf, f)
+	}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(meta.Args)))
+	for _, a := range meta.Args {
+		buf = appendSignedField(buf, a)
+	}
+	for _, f := range []string{meta.TypeHint, resp.EncryptedPayloadURL, resp.ScriptAADVersion} {
+		buf = appendSignedField(buf, f)
+	}
+	return buf
+}
+
+func appendSignedField(buf []byte, f string) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(f)))
+	return append(buf, f...)
+}
+
+// CanonicalActivationChallenge 返回待签名的规范化挑战响应；offered 为客户端在请求中声明的算法
+func CanonicalActivationChallenge(resp *ActivationChallengeResponse, installSessionToken string, offered []string) []byte {
+	buf := appendSignedField([]byte(challengeSignatureDomain), installSessionToken)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(offered)))
+	for _, alg := range offered {
+		buf = appendSignedField(buf, alg)
+	}
+	for _, f := range []string{resp.ChallengeSalt, resp.ServerNonce, resp.KeyAgreement} {
+		buf = appendSignedField(buf, f)
+	}
+	return buf
+}
+
+// verifySignature 验证 base64 编码的分离签名
+func verifySignature(pub ed25519.PublicKey, signature string, message []byte) error {
+	if signature == "" {
+		return fmt.Errorf("%w: 响应未签名", ErrResponseSignature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: 签名格式无效", ErrResponseSignature)
+	}
+	if !ed25519.Verify(pub, message, sig) {
+		return ErrResponseSignature
+	}
+	return nil
+}
+
+// VerifyActivateResponse 验证激活响应的分离签名
+func VerifyActivateResponse(pub ed25519.PublicKey, resp *ActivateMachineResponse, binding ResponseBinding) error {
+	return verifySignature(pub, resp.Signature, CanonicalActivateResponse(resp, binding))
+}
+
+// VerifyActivationChallenge 验证挑战响应的分离签名
+func VerifyActivationChallenge(pub ed25519.PublicKey, resp *ActivationChallengeResponse, installSessionToken string, offered []string) error {
+	return verifySignature(pub, resp.Signature, CanonicalActivationChallenge(resp, installSessionToken, offered))
+}
+
+// SignActivateResponse 服务端一侧的参考实现：返回写入 signature 字段的 base64 签名
+func SignActivateResponse(priv ed25519.PrivateKey, resp *ActivateMachineResponse, binding ResponseBinding) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(priv, CanonicalActivateResponse(resp, binding)))
+}
+
+// SignActivationChallenge 服务端一侧的参考实现：返回挑战响应 signature 字段的 base64 签名
+func SignActivationChallenge(priv ed25519.PrivateKey, resp *ActivationChallengeResponse, installSessionToken string, offered []string) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(priv, CanonicalActivationChallenge(resp, installSessionToken, offered)))
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+)
+
+func signedTestResponse(t *testing.T) (ed25519.PublicKey, *ActivateMachineResponse, ResponseBinding) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &ActivateMachineResponse{
+		EncryptedSessionScriptKey: "c3Nr",
+		EncryptedScriptBlob:       "YmxvYg==",
+		KeyAgreement:              KeyAgreementRSAOAEP,
+		ScriptExecutionMetadata:   ScriptExecutionMetadata{Interpreter: "bash", Args: []string{"-s"}, TypeHint: "shell"},
+		ScriptAADVersion:          "v2",
+	}
+	binding := ResponseBinding{
+		ClientPublicKey:     "cnNhLXB1YmxpYy1rZXk=",
+		InstallSessionToken: "token",
+		ChallengeSalt:       bytes.Repeat([]byte{1}, MinChallengeSaltSize),
+		ServerNonce:         bytes.Repeat([]byte{2}, MinServerNonceSize),
+	}
+	resp.Signature = SignActivateResponse(priv, resp, binding)
+	return pub, resp, binding
+}
+
+func TestVerifyActivateResponse(t *testing.T) {
+	pub, resp, binding := signedTestResponse(t)
+	if err := VerifyActivateResponse(pub, resp, binding); err != nil {
+		t.Fatal(err)
+	}
+	tamper := map[string]func(r *ActivateMachineResponse, b *ResponseBinding){
+		"令牌": func(r *ActivateMachineResponse, b *ResponseBinding) { b.InstallSessionToken = "other" },
+		"挑战盐值": func(r *ActivateMachineResponse, b *ResponseBinding) {
+			b.ChallengeSalt = bytes.Repeat([]byte{3}, MinChallengeSaltSize)
+		},
+		"key_agreement": func(r *ActivateMachineResponse, b *ResponseBinding) { r.KeyAgreement = "" },
+		"参数": func(r *ActivateMachineResponse, b *ResponseBinding) {
+			r.ScriptExecutionMetadata.Args = []string{"-c", "id"}
+		},
+		"script_aad_version": func(r *ActivateMachineResponse, b *ResponseBinding) { r.ScriptAADVersion = ScriptAADV1 },
+		"type_hint": func(r *ActivateMachineResponse, b *ResponseBinding) {
+			r.ScriptExecutionMetadata.TypeHint = PayloadTypeHintStream
+		},
+		"encrypted_payload_url": func(r *ActivateMachineResponse, b *ResponseBinding) {
+			r.EncryptedPayloadURL = "https://attacker.example/payload"
+		},
+		"未签名": func(r *ActivateMachineResponse, b *ResponseBinding) { r.Signature = "" },
+	}
+	for name, fn := range tamper {
+		r, b := *resp, binding
+		fn(&r, &b)
+		if err := VerifyActivateResponse(pub, &r, b); !errors.Is(err, ErrResponseSignature) {
+			t.Errorf("改写%s后应验证失败: %v", name, err)
+		}
+	}
+}
+
+// 复用的 RSA 私钥使 client_public_key 在重试间不变：旧的已签名响应在新挑战下不能通过验证
+func TestActivateResponseReplayWithReusedKey(t *testing.T) {
+	pub, old, oldBinding := signedTestResponse(t)
+	retry := oldBinding
+	retry.ServerNonce = bytes.Repeat([]byte{4}, MinServerNonceSize)
+	if err := VerifyActivateResponse(pub, old, retry); !errors.Is(err, ErrResponseSignature) {
+		t.Fatalf("旧响应在新挑战下应验证失败: %v", err)
+	}
+}
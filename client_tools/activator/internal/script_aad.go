@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// * 脚本密文的 AES-GCM 关联数据（AAD）
+// * 执行元数据以明文 JSON 与密文并列传输，AAD 将其与密文绑定：替换解释器、参数或类型提示后解密即失败。
+// *
+// * script-aad-v1: 域标签，随后每个字段依次为 uint32 BE 长度 | 字段字节:
+// *   版本 | install_session_token | platform_info | 系统信息规范版本 |
+// *   interpreter | 参数个数(uint32 BE) | 各参数 | type_hint
+// * 客户端在请求中声明支持的版本，服务端在响应中回传所用版本；不支持的版本（包括未回传）一律拒绝。
+
+// ScriptAADV1 当前 AAD 版本
+const ScriptAADV1 = "script-aad-v1"
+
+// SupportedScriptAADVersions 客户端支持的 AAD 版本
+var SupportedScriptAADVersions = []string{ScriptAADV1}
+
+const scriptAADDomain = "activator/v1 script-aad"
+
+// ScriptAADContext 参与 AAD 的请求侧字段
+type ScriptAADContext struct {
+	InstallSessionToken string
+	PlatformInfo        string
+	SysInfoSpec         string
+}
+
+// BuildScriptAAD 按版本构造 AAD
+func BuildScriptAAD(version string, ctx ScriptAADContext, meta ScriptExecutionMetadata) ([]byte, error) {
+	if version != ScriptAADV1 {
+		return nil, fmt.Errorf("不支持的脚本 AAD 版本: %q（支持 %s）", version, strings.Join(SupportedScriptAADVersions, ", "))
+	}
+	buf := []byte(scriptAADDomain)
+	for _, f := range []string{version, ctx.InstallSessionToken, ctx.PlatformInfo, ctx.SysInfoSpec, meta.Interpreter} {
+		buf = appendSignedField(buf, f)
+	}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(meta.Args)))
+	for _, a := range meta.Args {
+		buf = appendSignedField(buf, a)
+	}
+	return appendSignedField(buf, meta.TypeHint), nil
+}
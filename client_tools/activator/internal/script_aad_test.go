@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+// encryptScriptBlob 以整体 AES-GCM 加密（sealScriptPayload）；nonce 读自 random
+func encryptScriptBlob(t testing.TB, random io.Reader, ssk, script, aad []byte, compress bool) []byte {
+	t.Helper()
+	blob, err := sealScriptPayload(ssk, script, aad, compress, random)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blob
+}
+
+// encryptStreamBlob 以分段算法加密；nonce 前缀读自 random
+func encryptStreamBlob(t testing.TB, random io.Reader, ssk, payload, aad []byte, compress bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := newStreamEncryptWriter(ssk, &buf, aad, compress, random)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// openScriptBlob 按客户端的解密路径（OpenScriptPayload）读出全部明文
+func openScriptBlob(ssk, blob, aad []byte) ([]byte, error) {
+	r, err := OpenScriptPayload(ssk, bytes.NewReader(blob), aad)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func testSSK(t testing.TB) []byte {
+	ssk := make([]byte, 32)
+	rand.Read(ssk)
+	return ssk
+}
+
+var (
+	testScriptAADContext = ScriptAADContext{
+		InstallSessionToken: "token-0001",
+		PlatformInfo:        "linux-amd64,SysInfoSpec=V1.1",
+		SysInfoSpec:         SysInfoSpecVersion,
+	}
+	testScriptMeta = ScriptExecutionMetadata{Interpreter: "bash", Args: []string{"-s"}, TypeHint: "shell"}
+)
+
+// 逐项替换元数据与上下文后解密必须失败，原样解密必须成功
+func TestScriptAADTamper(t *testing.T) {
+	ssk := testSSK(t)
+	script := []byte("echo configured\n")
+	aad, err := BuildScriptAAD(ScriptAADV1, testScriptAADContext, testScriptMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs := map[string][]byte{
+		"整体加密":    encryptScriptBlob(t, rand.Reader, ssk, script, aad, true),
+		"分段加密":    encryptStreamBlob(t, rand.Reader, ssk, script, aad, false),
+		"分段加密+压缩": encryptStreamBlob(t, rand.Reader, ssk, script, aad, true),
+	}
+	cases := []struct {
+		name string
+		ctx  func(c *ScriptAADContext)
+		meta func(m *ScriptExecutionMetadata)
+	}{
+		{"替换解释器", nil, func(m *ScriptExecutionMetadata) { m.Interpreter = "python3" }},
+		{"替换参数", nil, func(m *ScriptExecutionMetadata) { m.Args = []string{"-c", "id"} }},
+		{"追加参数", nil, func(m *ScriptExecutionMetadata) { m.Args = append(m.Args, "-x") }},
+		{"参数拼接移位", nil, func(m *ScriptExecutionMetadata) { m.Interpreter, m.Args = "bash-s", nil }},
+		{"替换类型提示", nil, func(m *ScriptExecutionMetadata) { m.TypeHint = "python" }},
+		{"替换令牌", func(c *ScriptAADContext) { c.InstallSessionToken = "token-0002" }, nil},
+		{"替换平台", func(c *ScriptAADContext) { c.PlatformInfo = "windows-amd64,SysInfoSpec=V1.1" }, nil},
+		{"替换规范版本", func(c *ScriptAADContext) { c.SysInfoSpec = SysInfoSpecVersionV2 }, nil},
+	}
+	for kind, blob := range blobs {
+		t.Run(kind, func(t *testing.T) {
+			if got, err := openScriptBlob(ssk, blob, aad); err != nil || !bytes.Equal(got, script) {
+				t.Fatalf("原样解密失败: %v", err)
+			}
+			for _, tc := range cases {
+				c, m := testScriptAADContext, testScriptMeta
+				m.Args = append([]string(nil), testScriptMeta.Args...)
+				if tc.ctx != nil {
+					tc.ctx(&c)
+				}
+				if tc.meta != nil {
+					tc.meta(&m)
+				}
+				tampered, err := BuildScriptAAD(ScriptAADV1, c, m)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := openScriptBlob(ssk, blob, tampered); err == nil {
+					t.Errorf("%s：解密未失败", tc.name)
+				}
+			}
+		})
+	}
+}
+
+// 信封头部参与认证：翻转压缩标志位后解密必须失败
+func TestScriptAADTamperEnvelopeFlags(t *testing.T) {
+	ssk := testSSK(t)
+	aad, _ := BuildScriptAAD(ScriptAADV1, testScriptAADContext, testScriptMeta)
+	for _, blob := range [][]byte{
+		encryptScriptBlob(t, rand.Reader, ssk, []byte("echo configured\n"), aad, true),
+		encryptStreamBlob(t, rand.Reader, ssk, []byte("echo configured\n"), aad, false),
+	} {
+		e, err := ParseEnvelope(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blob[len(e.Header())-len(e.Nonce)-1] ^= EnvelopeFlagGzip
+		if _, err := openScriptBlob(ssk, blob, aad); err == nil {
+			t.Error("篡改信封标志位后解密未失败")
+		}
+	}
+}
+
+func TestScriptAADTamperVersion(t *testing.T) {
+	for _, version := range []string{"", "script-aad-v0", "script-aad-v2"} {
+		if _, err := BuildScriptAAD(version, testScriptAADContext, testScriptMeta); err == nil {
+			t.Errorf("AAD 版本 %q 未被拒绝", version)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// * 敏感数据缓冲区
+// * SecretBuffer 的内存位于独立分配并锁定（mlock / VirtualLock）的整页上，不会被换出到交换区，
+// * 在 Linux 上同时排除出核心转储；锁定失败（如 RLIMIT_MEMLOCK 过小）时退化为未锁定的内存，清零保证不变。
+// * 所有存活的缓冲区（以及 RSA 私钥等无法放入缓冲区的密钥）登记在全局表中，
+// * WipeAllSecrets 在任何退出路径前统一清零，包括 printError 的 os.Exit 与信号退出。
+// * 局限：Go 字符串不可变，令牌等字符串无法擦除；ecdh / mlkem 私钥与 AES 轮密钥为不透明类型，只能丢弃引用。
+// * 密文及其 base64 形式不属于敏感数据，不做处理。
+
+// SecretBuffer 锁定内存中的敏感字节；零值不可用，使用 NewSecretBuffer / SecretFrom 构造
+type SecretBuffer struct {
+	mu     sync.Mutex
+	mem    []byte // 整页分配
+	b      []byte // mem[:n]
+	locked bool
+}
+
+// NewSecretBuffer 分配 n 字节的锁定内存
+func NewSecretBuffer(n int) *SecretBuffer {
+	s := &SecretBuffer{}
+	size := (n + os.Getpagesize() - 1) &^ (os.Getpagesize() - 1)
+	if size == 0 {
+		size = os.Getpagesize()
+	}
+	s.mem, s.locked = allocLocked(size)
+	s.b = s.mem[:n]
+	registerWiper(s)
+	return s
+}
+
+// SecretFrom 将 b 复制到锁定内存并擦除 b
+func SecretFrom(b []byte) *SecretBuffer {
+	s := NewSecretBuffer(len(b))
+	copy(s.b, b)
+	WipeBytes(b)
+	return s
+}
+
+// Bytes 返回缓冲区内容；Destroy 之后为 nil
+func (s *SecretBuffer) Bytes() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b
+}
+
+// Len 返回内容长度
+func (s *SecretBuffer) Len() int {
+	return len(s.Bytes())
+}
+
+// Locked 报告内存是否已锁定
+func (s *SecretBuffer) Locked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.locked
+}
+
+// Destroy 清零并释放缓冲区；可重复调用
+func (s *SecretBuffer) Destroy() {
+	unregisterWiper(s)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mem == nil {
+		return
+	}
+	WipeBytes(s.mem)
+	freeLocked(s.mem, s.locked)
+	s.mem, s.b, s.locked = nil, nil, false
+}
+
+// wipe 仅清零不释放：进程即将退出时，其他 goroutine 可能仍持有 Bytes 返回的切片
+func (s *SecretBuffer) wipe() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	WipeBytes(s.mem)
+}
+
+// secretWiper 可在退出时清零的敏感数据
+type secretWiper interface {
+	wipe()
+}
+
+var secretRegistry = struct {
+	sync.Mutex
+	live map[secretWiper]struct{}
+}{live: map[secretWiper]struct{}{}}
+
+func registerWiper(w secretWiper) {
+	secretRegistry.Lock()
+	secretRegistry.live[w] = struct{}{}
+	secretRegistry.Unlock()
+}
+
+func unregisterWiper(w secretWiper) {
+	secretRegistry.Lock()
+	delete(secretRegistry.live, w)
+	secretRegistry.Unlock()
+}
+
+// ReadSecret 将 r 读到末尾，内容只存放在锁定内存中（容量按需倍增，旧缓冲区随即销毁）；
+// 超过 limit 字节或读取出错时销毁已读内容并返回错误
+func ReadSecret(r io.Reader, limit int) (*SecretBuffer, error) {
+	buf := NewSecretBuffer(min(limit+1, os.Getpagesize()))
+	n := 0
+	for {
+		if n == buf.Len() {
+			if n > limit {
+				buf.Destroy()
+				return nil, fmt.Errorf("内容超过 %d 字节", limit)
+			}
+			grown := NewSecretBuffer(min(limit+1, 2*n))
+			copy(grown.Bytes(), buf.Bytes())
+			buf.Destroy()
+			buf = grown
+		}
+		m, err := r.Read(buf.Bytes()[n:])
+		n += m
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			buf.Destroy()
+			return nil, err
+		}
+	}
+	if n > limit {
+		buf.Destroy()
+		return nil, fmt.Errorf("内容超过 %d 字节", limit)
+	}
+	buf.mu.Lock()
+	buf.b = buf.mem[:n]
+	buf.mu.Unlock()
+	return buf, nil
+}
+
+// WipeAllSecrets 清零所有尚未销毁的敏感数据；在 os.Exit 之前调用
+func WipeAllSecrets() {
+	secretRegistry.Lock()
+	live := secretRegistry.live
+	secretRegistry.live = map[secretWiper]struct{}{}
+	secretRegistry.Unlock()
+	for w := range live {
+		w.wipe()
+	}
+}
+
+// WipeBytes 覆盖敏感字节
+func WipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,23 @@
+//go:build linux || darwin
+
+package internal
+
+import "syscall"
+
+// allocLocked 以匿名私有映射分配整页内存（不在 Go 堆上）并尝试 mlock
+func allocLocked(size int) ([]byte, bool) {
+	mem, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return make([]byte, size), false
+	}
+	excludeFromCoreDump(mem)
+	return mem, syscall.Mlock(mem) == nil
+}
+
+// freeLocked 解锁并释放 allocLocked 分配的内存
+func freeLocked(mem []byte, locked bool) {
+	if locked {
+		_ = syscall.Munlock(mem)
+	}
+	_ = syscall.Munmap(mem)
+}
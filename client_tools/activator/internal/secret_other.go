@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package internal
+
+// allocLocked 不支持锁定内存的平台：普通分配，仅保证清零
+func allocLocked(size int) ([]byte, bool) {
+	return make([]byte, size), false
+}
+
+func freeLocked([]byte, bool) {}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadSecret(t *testing.T) {
+	page := os.Getpagesize()
+	for _, n := range []int{0, 1, page - 1, page, page + 1, 5*page + 7} {
+		want := bytes.Repeat([]byte{0xa5}, n)
+		s, err := ReadSecret(iotest.OneByteReader(bytes.NewReader(want)), 8*page)
+		if err != nil {
+			t.Fatalf("%d 字节: %v", n, err)
+		}
+		if !bytes.Equal(s.Bytes(), want) {
+			t.Fatalf("%d 字节: 内容不一致（得到 %d 字节）", n, s.Len())
+		}
+		s.Destroy()
+	}
+	if s, err := ReadSecret(bytes.NewReader(make([]byte, page)), page); err != nil || s.Len() != page {
+		t.Fatalf("恰好等于上限应成功: %v", err)
+	}
+	if _, err := ReadSecret(bytes.NewReader(make([]byte, page+1)), page); err == nil || !strings.Contains(err.Error(), "超过") {
+		t.Fatalf("超过上限应失败: %v", err)
+	}
+	// 截断（解密流在末段之前出错）时不返回任何已读内容
+	broken := io.MultiReader(bytes.NewReader([]byte("echo partial\n")), iotest.ErrReader(ErrInvalidEnvelope))
+	if s, err := ReadSecret(broken, page); s != nil || !errors.Is(err, ErrInvalidEnvelope) {
+		t.Fatalf("读取出错应返回错误且不返回内容: %v", err)
+	}
+}
+
+// registered 报告 w 是否仍在全局表中
+func registered(w secretWiper) bool {
+	secretRegistry.Lock()
+	defer secretRegistry.Unlock()
+	_, ok := secretRegistry.live[w]
+	return ok
+}
+
+func TestSecretBufferDestroy(t *testing.T) {
+	s := NewSecretBuffer(100)
+	copy(s.Bytes(), bytes.Repeat([]byte{0xa5}, 100))
+	if !registered(s) {
+		t.Fatal("新建的缓冲区未登记")
+	}
+	s.Destroy()
+	if s.Bytes() != nil || s.Len() != 0 || s.Locked() {
+		t.Fatalf("销毁后仍可访问: %d 字节", s.Len())
+	}
+	if registered(s) {
+		t.Fatal("销毁后仍在全局表中")
+	}
+	s.Destroy()
+
+	// 映射已释放无法回读，改用堆内存验证 Destroy 在释放前清零
+	mem := bytes.Repeat([]byte{0xa5}, os.Getpagesize())
+	h := &SecretBuffer{mem: mem, b: mem[:100]}
+	h.Destroy()
+	h.Destroy()
+	if !bytes.Equal(mem, make([]byte, len(mem))) {
+		t.Fatal("销毁后内存未清零")
+	}
+}
+
+func TestWipeAllSecrets(t *testing.T) {
+	live := NewSecretBuffer(64)
+	copy(live.Bytes(), bytes.Repeat([]byte{0xa5}, 64))
+	destroyed := NewSecretBuffer(64)
+	destroyed.Destroy()
+
+	WipeAllSecrets()
+	// 只清零不释放，其他 goroutine 持有的切片仍可访问
+	if got := live.Bytes(); len(got) != 64 || !bytes.Equal(got, make([]byte, 64)) {
+		t.Fatalf("登记的缓冲区未清零: %x", got)
+	}
+	if registered(live) {
+		t.Fatal("清零后仍在全局表中")
+	}
+	live.Destroy()
+	WipeAllSecrets()
+}
+
+func TestSecretFrom(t *testing.T) {
+	src := []byte("session-script-key-0123456789abc")
+	want := bytes.Clone(src)
+	s := SecretFrom(src)
+	defer s.Destroy()
+	if !bytes.Equal(s.Bytes(), want) {
+		t.Fatalf("内容不一致: 期望 %q, 得到 %q", want, s.Bytes())
+	}
+	if !bytes.Equal(src, make([]byte, len(src))) {
+		t.Fatalf("来源未擦除: %q", src)
+	}
+	// 空来源同样可用
+	empty := SecretFrom(nil)
+	defer empty.Destroy()
+	if empty.Len() != 0 {
+		t.Fatalf("空来源: 得到 %d 字节", empty.Len())
+	}
+}
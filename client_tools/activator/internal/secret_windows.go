@@ -0,0 +1,28 @@
+//go:build windows
+
+package internal
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// allocLocked 在堆上多分配一页并取页对齐的部分，使锁定的页不与其他对象共享，再尝试 VirtualLock
+func allocLocked(size int) ([]byte, bool) {
+	page := os.Getpagesize()
+	raw := make([]byte, size+page)
+	off := 0
+	if rem := int(uintptr(unsafe.Pointer(&raw[0])) % uintptr(page)); rem != 0 {
+		off = page - rem
+	}
+	mem := raw[off : off+size : off+size]
+	return mem, syscall.VirtualLock(uintptr(unsafe.Pointer(&mem[0])), uintptr(size)) == nil
+}
+
+// freeLocked 解锁 allocLocked 分配的内存，之后交还给 GC
+func freeLocked(mem []byte, locked bool) {
+	if locked {
+		_ = syscall.VirtualUnlock(uintptr(unsafe.Pointer(&mem[0])), uintptr(len(mem)))
+	}
+}
@@ -0,0 +1,193 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// * SMBIOS 表解析
+// * 直接解码 /sys/firmware/dmi/tables 下的入口点与结构表，
+// * 输出与 dmidecode -s <keyword> 逐字节一致，替代对 dmidecode 的依赖。
+
+// SMBIOSTablesDir Linux 下导出 SMBIOS 表的目录
+const SMBIOSTablesDir = "/sys/firmware/dmi/tables"
+
+// SMBIOS 已解析的 SMBIOS 表
+type SMBIOS struct {
+	Version    uint16 // 主版本<<8 | 次版本，如 0x0206
+	structures []smbiosStructure
+}
+
+type smbiosStructure struct {
+	Type      byte
+	Formatted []byte   // 含 4 字节头部的格式化区
+	Strings   [][]byte // 字符串集（下标 1 对应 Strings[0]）
+}
+
+// smbiosField dmidecode -s 关键字对应的结构类型与偏移
+type smbiosField struct {
+	Type   byte
+	Offset int
+}
+
+var smbiosKeywords = map[string]smbiosField{
+	"system-manufacturer":     {1, 0x04},
+	"system-product-name":     {1, 0x05},
+	"system-version":          {1, 0x06},
+	"system-serial-number":    {1, 0x07},
+	"system-uuid":             {1, 0x08},
+	"baseboard-manufacturer":  {2, 0x04},
+	"baseboard-product-name":  {2, 0x05},
+	"baseboard-version":       {2, 0x06},
+	"baseboard-serial-number": {2, 0x07},
+}
+
+// ReadSMBIOS 从目录读取 smbios_entry_point 与 DMI 并解析
+func ReadSMBIOS(dir string) (*SMBIOS, error) {
+	ep, err := os.ReadFile(filepath.Join(dir, "smbios_entry_point"))
+	if err != nil {
+		return nil, err
+	}
+	table, err := os.ReadFile(filepath.Join(dir, "DMI"))
+	if err != nil {
+		return nil, err
+	}
+	return ParseSMBIOS(ep, table)
+}
+
+// ParseSMBIOS 解析入口点与结构表
+func ParseSMBIOS(entryPoint, table []byte) (*SMBIOS, error) {
+	ver, err := parseSMBIOSEntryPoint(entryPoint)
+	if err != nil {
+		return nil, err
+	}
+	s := &SMBIOS{Version: ver}
+	for len(table) >= 4 {
+		typ, length := table[0], int(table[1])
+		// 与 dmidecode 一致：头部长度非法即视为表损坏，停止解析
+		if length < 4 || length > len(table) {
+			break
+		}
+		st := smbiosStructure{Type: typ, Formatted: table[:length]}
+		rest := table[length:]
+		end := bytes.Index(rest, []byte{0, 0})
+		if end < 0 {
+			break
+		}
+		if end > 0 {
+			st.Strings = bytes.Split(rest[:end], []byte{0})
+		}
+		s.structures = append(s.structures, st)
+		table = rest[end+2:]
+		if typ == 127 {
+			break
+		}
+	}
+	if len(s.structures) == 0 {
+		return nil, errors.New("SMBIOS 结构表为空")
+	}
+	return s, nil
+}
+
+// parseSMBIOSEntryPoint 解析入口点并返回 SMBIOS 版本
+func parseSMBIOSEntryPoint(ep []byte) (uint16, error) {
+	switch {
+	case len(ep) >= 24 && bytes.HasPrefix(ep, []byte("_SM3_")):
+		return uint16(ep[7])<<8 | uint16(ep[8]), nil
+	case len(ep) >= 31 && bytes.HasPrefix(ep, []byte("_SM_")):
+		ver := uint16(ep[6])<<8 | uint16(ep[7])
+		// 与 dmidecode 相同的已知固件版本号错误修正
+		switch ver {
+		case 0x021F, 0x0221:
+			ver = 0x0203
+		case 0x0233:
+			ver = 0x0206
+		}
+		return ver, nil
+	case len(ep) >= 15 && bytes.HasPrefix(ep, []byte("_DMI_")):
+		return uint16(ep[14]&0xF0)<<4 | uint16(ep[14]&0x0F), nil
+	}
+	return 0, errors.New("无法识别的 SMBIOS 入口点")
+}
+
+// String 返回与 `dmidecode -s keyword` 相同的输出（每个匹配结构一行）
+func (s *SMBIOS) String(keyword string) (string, error) {
+	f, ok := smbiosKeywords[keyword]
+	if !ok {
+		return "", fmt.Errorf("不支持的 SMBIOS 关键字: %s", keyword)
+	}
+	var sb strings.Builder
+	for _, st := range s.structures {
+		if st.Type != f.Type || f.Offset >= len(st.Formatted) {
+			continue
+		}
+		if keyword == "system-uuid" {
+			if f.Offset+16 > len(st.Formatted) {
+				continue
+			}
+			sb.WriteString(formatSMBIOSUUID(st.Formatted[f.Offset:f.Offset+16], s.Version))
+		} else {
+			sb.WriteString(st.str(st.Formatted[f.Offset]))
+		}
+		sb.WriteByte('\n')
+	}
+	if sb.Len() == 0 {
+		return "", fmt.Errorf("SMBIOS 中不存在 %s", keyword)
+	}
+	return sb.String(), nil
+}
+
+// str 按 dmidecode 规则取字符串：0 为未指定，越界为坏索引，控制字符替换为 '.'
+func (st smbiosStructure) str(index byte) string {
+	if index == 0 {
+		return "Not Specified"
+	}
+	if int(index) > len(st.Strings) || len(st.Strings[index-1]) == 0 {
+		return "<BAD INDEX>"
+	}
+	b := bytes.Clone(st.Strings[index-1])
+	for i, c := range b {
+		if c < 32 || c == 127 {
+			b[i] = '.'
+		}
+	}
+	return string(b)
+}
+
+// formatSMBIOSUUID 格式化系统 UUID；SMBIOS 2.6 起前三段按小端存储
+func formatSMBIOSUUID(p []byte, ver uint16) string {
+	only00, onlyFF := true, true
+	for _, c := range p {
+		only00 = only00 && c == 0x00
+		onlyFF = onlyFF && c == 0xFF
+	}
+	if onlyFF {
+		return "Not Present"
+	}
+	if only00 {
+		return "Not Settable"
+	}
+	if ver >= 0x0206 {
+		return fmt.Sprintf("%08X-%04X-%04X-%X-%X",
+			binary.LittleEndian.Uint32(p[0:4]), binary.LittleEndian.Uint16(p[4:6]), binary.LittleEndian.Uint16(p[6:8]), p[8:10], p[10:16])
+	}
+	return fmt.Sprintf("%X-%X-%X-%X-%X", p[0:4], p[4:6], p[6:8], p[8:10], p[10:16])
+}
+
+// smbiosSource 返回读取 SMBIOS 表中指定关键字的采集函数；同一次 Collect 内表只读取、解析一次
+func smbiosSource(keyword string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		s, err := memoize(ctx, "smbios", func() (*SMBIOS, error) { return ReadSMBIOS(SMBIOSTablesDir) })
+		if err != nil {
+			// /sys/firmware/dmi/tables 仅 root 可读
+			return "", permissionAsElevation(err)
+		}
+		return s.String(keyword)
+	}
+}
@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// testdata/smbios/<名称>/ 下为 smbios_entry_point 与 DMI 转储，dmidecode.json 为对应的 `dmidecode -s <关键字>` 输出（null 表示无该结构）
+func TestParseSMBIOSFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "smbios", "*", "dmidecode.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("缺少 SMBIOS 样本: %v", err)
+	}
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			raw, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want map[string]*string
+			if err := json.Unmarshal(raw, &want); err != nil {
+				t.Fatal(err)
+			}
+			s, err := ReadSMBIOS(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for keyword, w := range want {
+				got, err := s.String(keyword)
+				switch {
+				case w == nil && err == nil:
+					t.Errorf("%s: 期望不存在, 得到 %q", keyword, got)
+				case w != nil && err != nil:
+					t.Errorf("%s: %v", keyword, err)
+				case w != nil && got != *w:
+					t.Errorf("%s: 期望 %q, 得到 %q", keyword, *w, got)
+				}
+			}
+		})
+	}
+}
+
+// 真机转储（captured-*）需覆盖 2.x 与 3.x 入口点，以验证人工样本未涵盖的固件差异；采集方法见 testdata/smbios/README.md
+func TestSMBIOSCapturedVersions(t *testing.T) {
+	dirs, err := filepath.Glob(filepath.Join("testdata", "smbios", "captured-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	majors := map[uint16][]string{}
+	for _, dir := range dirs {
+		s, err := ReadSMBIOS(dir)
+		if err != nil {
+			t.Fatalf("%s: %v", dir, err)
+		}
+		raw, err := os.ReadFile(filepath.Join(dir, "dmidecode.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want map[string]*string
+		if err := json.Unmarshal(raw, &want); err != nil {
+			t.Fatal(err)
+		}
+		for keyword := range smbiosKeywords {
+			if _, ok := want[keyword]; !ok {
+				t.Fatalf("%s: dmidecode.json 缺少关键字 %s", dir, keyword)
+			}
+		}
+		majors[s.Version>>8] = append(majors[s.Version>>8], filepath.Base(dir))
+	}
+	var missing []string
+	for _, major := range []uint16{2, 3} {
+		if len(majors[major]) == 0 {
+			missing = append(missing, fmt.Sprintf("%d.x", major))
+		}
+	}
+	if len(missing) > 0 {
+		t.Skipf("缺少 SMBIOS %v 真机转储（现有: %v），见 testdata/smbios/README.md", missing, majors)
+	}
+}
+
+func TestParseSMBIOSRejectsUnknownEntryPoint(t *testing.T) {
+	table, err := os.ReadFile(filepath.Join("testdata", "smbios", "qemu-sm-2.8", "DMI"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseSMBIOS([]byte("_XX_ not an entry point"), table); err == nil {
+		t.Fatal("未知入口点应失败")
+	}
+	if _, err := ParseSMBIOS([]byte("_SM_"), table); err == nil {
+		t.Fatal("截断的入口点应失败")
+	}
+}
+
+func TestCollectMemoizesAcrossSlots(t *testing.T) {
+	dir, err := filepath.Abs(filepath.Join("testdata", "smbios", "qemu-sm-2.8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Collect 的采集日志写入工作目录下的 log.txt
+	t.Chdir(t.TempDir())
+	var reads atomic.Int32
+	r := NewRegistry()
+	for slot := 0; slot < SysInfoSlotCount; slot++ {
+		r.Register(NewCollector("smbios", slot, "test", false, func(ctx context.Context) (string, error) {
+			s, err := memoize(ctx, "smbios", func() (*SMBIOS, error) {
+				reads.Add(1)
+				return ReadSMBIOS(dir)
+			})
+			if err != nil {
+				return SysInfoUnavailable, err
+			}
+			return s.String("system-manufacturer")
+		}))
+	}
+	for _, res := range r.Collect(context.Background(), "test") {
+		if res.Value != "QEMU\n" {
+			t.Fatalf("槽位结果: %+v", res)
+		}
+	}
+	if n := reads.Load(); n != 1 {
+		t.Fatalf("一次 Collect 中 SMBIOS 表被读取 %d 次", n)
+	}
+	r.Collect(context.Background(), "test")
+	if n := reads.Load(); n != 2 {
+		t.Fatalf("每次 Collect 应重新读取, 实际累计 %d 次", n)
+	}
+}
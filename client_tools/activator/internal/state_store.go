@@ -0,0 +1,242 @@
+package internal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// * 本地密封状态存储
+// * 激活器落盘的一切（私钥、回执、重试状态）均以硬件绑定密钥 AES-GCM 加密，
+// * 状态目录被复制到其他机器后绑定密钥不同，任何条目都无法解封。
+// *
+// * 条目格式: magic "ACST" | version(1) | nonce(12) | ciphertext+tag
+// * AAD = magic | version | 条目名，条目文件之间互换同样无法解封。
+
+// StateDirEnv 覆盖默认状态目录的环境变量
+const StateDirEnv = "ACTIVATOR_STATE_DIR"
+
+// 状态条目名
+const (
+	StatePrivateKey = "private_key"
+	StateReceipt    = "receipt"
+	StateRetry      = "retry"
+)
+
+const (
+	sealedStateMagic   = "ACST"
+	sealedStateVersion = 1
+	sealedStateExt     = ".sealed"
+)
+
+var (
+	// ErrStateNotFound 条目不存在
+	ErrStateNotFound = errors.New("状态条目不存在")
+	// ErrStateUnsealFailed 条目无法以本机绑定密钥解封（来自其他机器、硬件已变化或已被篡改）
+	ErrStateUnsealFailed = errors.New("状态条目无法解封：不属于本机或已被篡改")
+)
+
+// ActivationReceipt 激活成功后留存的回执，不含令牌与脚本明文
+type ActivationReceipt struct {
+	TokenFingerprint string    `json:"token_fingerprint"`
+	ActivatedAt      time.Time `json:"activated_at"`
+	Platform         string    `json:"platform"`
+	Interpreter      string    `json:"interpreter"`
+	ScriptSHA256     string    `json:"script_sha256"`
+	Duration         string    `json:"duration"`
+	// HardwareSlots 本次激活发送的槽位哈希，HardwareSalt 为计算所用的挑战盐值（仅保存在密封的本地状态中）；
+	// 之后以新令牌激活、硬件匹配失败时，以该盐值重新计算当前槽位即可在本地比对出发生变化的槽位
+	HardwareSalt  []byte             `json:"hardware_salt,omitempty"`
+	HardwareSlots []HardwareSlotHash `json:"hardware_slots,omitempty"`
+}
+
+// RetryState 激活失败后的重试状态，成功后清除
+type RetryState struct {
+	TokenFingerprint string    `json:"token_fingerprint"`
+	Attempts         int       `json:"attempts"`
+	LastAttempt      time.Time `json:"last_attempt"`
+	LastStage        string    `json:"last_stage"`
+	LastError        string    `json:"last_error"`
+}
+
+// StateStore 以硬件绑定密钥密封的状态目录
+type StateStore struct {
+	dir  string
+	aead cipher.AEAD
+}
+
+// DefaultStateDir 返回用户状态目录下的 activator 子目录：
+// Linux $XDG_STATE_HOME（默认 ~/.local/state），macOS ~/Library/Application Support，Windows %LocalAppData%
+func DefaultStateDir() (string, error) {
+	if dir := os.Getenv(StateDirEnv); dir != "" {
+		return dir, nil
+	}
+	switch runtime.GOOS {
+	case "windows":
+		if dir := os.Getenv("LocalAppData"); dir != "" {
+			return filepath.Join(dir, "activator"), nil
+		}
+	case "linux":
+		if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+			return filepath.Join(dir, "activator"), nil
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, ".local", "state", "activator"), nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "activator"), nil
+}
+
+// OpenStateStore 打开（必要时创建）状态目录；key 为 32 字节的硬件绑定密钥
+func OpenStateStore(dir string, key []byte) (*StateStore, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("状态密钥长度无效: %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &StateStore{dir: dir, aead: aead}, nil
+}
+
+// TokenFingerprint 令牌指纹（SHA-256 前 8 字节），用于关联回执与重试状态而不落盘令牌本身
+func TokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Dir 返回状态目录
+func (s *StateStore) Dir() string {
+	return s.dir
+}
+
+func (s *StateStore) path(name string) string {
+	return filepath.Join(s.dir, name+sealedStateExt)
+}
+
+func sealedStateAAD(name string) []byte {
+	aad := append([]byte(sealedStateMagic), sealedStateVersion)
+	return append(aad, name...)
+}
+
+// Put 密封并原子写入条目
+func (s *StateStore) Put(name string, plaintext []byte) error {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	out := make([]byte, 0, len(sealedStateMagic)+1+len(nonce)+len(plaintext)+s.aead.Overhead())
+	out = append(out, sealedStateMagic...)
+	out = append(out, sealedStateVersion)
+	out = append(out, nonce...)
+	out = s.aead.Seal(out, nonce, plaintext, sealedStateAAD(name))
+
+	tmp, err := os.CreateTemp(s.dir, "."+name+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(name))
+}
+
+// Get 读取并解封条目；不存在返回 ErrStateNotFound，无法解封返回 ErrStateUnsealFailed
+func (s *StateStore) Get(name string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrStateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	header := len(sealedStateMagic) + 1
+	if len(data) < header+s.aead.NonceSize()+s.aead.Overhead() ||
+		string(data[:len(sealedStateMagic)]) != sealedStateMagic || data[len(sealedStateMagic)] != sealedStateVersion {
+		return nil, ErrStateUnsealFailed
+	}
+	nonce := data[header : header+s.aead.NonceSize()]
+	plaintext, err := s.aead.Open(nil, nonce, data[header+s.aead.NonceSize():], sealedStateAAD(name))
+	if err != nil {
+		return nil, ErrStateUnsealFailed
+	}
+	return plaintext, nil
+}
+
+// Delete 删除条目，不存在时不报错
+func (s *StateStore) Delete(name string) error {
+	if err := os.Remove(s.path(name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// PutJSON 以 JSON 序列化后密封写入
+func (s *StateStore) PutJSON(name string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	defer WipeBytes(raw)
+	return s.Put(name, raw)
+}
+
+// GetJSON 解封并反序列化条目
+func (s *StateStore) GetJSON(name string, v any) error {
+	raw, err := s.Get(name)
+	if err != nil {
+		return err
+	}
+	defer WipeBytes(raw)
+	return json.Unmarshal(raw, v)
+}
+
+// LoadSealedPrivateKey 读取上次未完成激活时密封保存的 RSA 私钥；
+// 不存在返回 ErrStateNotFound，无法解封返回 ErrStateUnsealFailed
+func (s *StateStore) LoadSealedPrivateKey() (*rsa.PrivateKey, error) {
+	pemBytes, err := s.Get(StatePrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	defer WipeBytes(pemBytes)
+	return DecodePEMToPrivateKey(pemBytes)
+}
+
+// SealPrivateKey 密封保存 RSA 私钥，供重试时复用
+func (s *StateStore) SealPrivateKey(key *rsa.PrivateKey) error {
+	pemBytes, err := EncodePrivateKeyToPEM(key)
+	if err != nil {
+		return err
+	}
+	defer pemBytes.Destroy()
+	return s.Put(StatePrivateKey, pemBytes.Bytes())
+}
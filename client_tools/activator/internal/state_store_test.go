@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testStateStore(t *testing.T, dir string, fill byte) *StateStore {
+	t.Helper()
+	s, err := OpenStateStore(dir, bytes.Repeat([]byte{fill}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStateStoreRoundTrip(t *testing.T) {
+	s := testStateStore(t, t.TempDir(), 1)
+	if _, err := s.Get(StateRetry); !errors.Is(err, ErrStateNotFound) {
+		t.Fatalf("不存在的条目: 期望 ErrStateNotFound, 得到 %v", err)
+	}
+	want := RetryState{TokenFingerprint: TokenFingerprint("token"), Attempts: 2, LastStage: "activate"}
+	if err := s.PutJSON(StateRetry, want); err != nil {
+		t.Fatal(err)
+	}
+	var got RetryState
+	if err := s.GetJSON(StateRetry, &got); err != nil || got != want {
+		t.Fatalf("期望 %+v, 得到 %+v (%v)", want, got, err)
+	}
+	raw, err := os.ReadFile(filepath.Join(s.Dir(), StateRetry+sealedStateExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("activate")) || bytes.Contains(raw, []byte(want.TokenFingerprint)) {
+		t.Fatal("条目应以密文落盘")
+	}
+	if err := s.Delete(StateRetry); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(StateRetry); err != nil {
+		t.Fatalf("重复删除不应报错: %v", err)
+	}
+	if _, err := s.Get(StateRetry); !errors.Is(err, ErrStateNotFound) {
+		t.Fatalf("删除后: 期望 ErrStateNotFound, 得到 %v", err)
+	}
+}
+
+func TestStateStoreOtherMachine(t *testing.T) {
+	dir := t.TempDir()
+	if err := testStateStore(t, dir, 1).Put(StateReceipt, []byte(`{"platform":"linux-amd64"}`)); err != nil {
+		t.Fatal(err)
+	}
+	// 状态目录被复制到其他机器（或硬件变化）后绑定密钥不同
+	if _, err := testStateStore(t, dir, 2).Get(StateReceipt); !errors.Is(err, ErrStateUnsealFailed) {
+		t.Fatalf("其他绑定密钥: 期望 ErrStateUnsealFailed, 得到 %v", err)
+	}
+	if _, err := OpenStateStore(dir, make([]byte, 16)); err == nil {
+		t.Fatal("密钥长度错误应失败")
+	}
+}
+
+func TestStateStoreRejectsSwappedEntries(t *testing.T) {
+	s := testStateStore(t, t.TempDir(), 1)
+	if err := s.PutJSON(StateRetry, RetryState{Attempts: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutJSON(StateReceipt, ActivationReceipt{Platform: "linux-amd64"}); err != nil {
+		t.Fatal(err)
+	}
+	retry, err := os.ReadFile(s.path(StateRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 以重试状态条目覆盖回执：AAD 含条目名，无法解封
+	if err := os.WriteFile(s.path(StateReceipt), retry, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(StateReceipt); !errors.Is(err, ErrStateUnsealFailed) {
+		t.Fatalf("互换条目: 期望 ErrStateUnsealFailed, 得到 %v", err)
+	}
+	// 以其他条目重放为私钥同样被拒绝
+	if err := os.WriteFile(s.path(StatePrivateKey), retry, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.LoadSealedPrivateKey(); !errors.Is(err, ErrStateUnsealFailed) {
+		t.Fatalf("重放为私钥: 期望 ErrStateUnsealFailed, 得到 %v", err)
+	}
+}
+
+func TestStateStoreRejectsCorruptedFile(t *testing.T) {
+	s := testStateStore(t, t.TempDir(), 1)
+	if err := s.Put(StateRetry, []byte(`{"attempts":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := os.ReadFile(s.path(StateRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flip := func(i int) []byte {
+		b := bytes.Clone(sealed)
+		b[i] ^= 1
+		return b
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"magic", flip(0)},
+		{"版本", flip(len(sealedStateMagic))},
+		{"nonce", flip(len(sealedStateMagic) + 1)},
+		{"密文", flip(len(sealed) - s.aead.Overhead() - 1)},
+		{"tag", flip(len(sealed) - 1)},
+		{"截断", sealed[:len(sealed)-1]},
+		{"仅头部", sealed[:len(sealedStateMagic)+1]},
+		{"空文件", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(s.path(StateRetry), tt.data, 0600); err != nil {
+				t.Fatal(err)
+			}
+			// 损坏的条目报告为无法解封，而不是当作不存在静默重置
+			if _, err := s.Get(StateRetry); !errors.Is(err, ErrStateUnsealFailed) {
+				t.Fatalf("期望 ErrStateUnsealFailed, 得到 %v", err)
+			}
+			var state RetryState
+			if err := s.GetJSON(StateRetry, &state); !errors.Is(err, ErrStateUnsealFailed) {
+				t.Fatalf("GetJSON: 期望 ErrStateUnsealFailed, 得到 %v", err)
+			}
+		})
+	}
+}
+
+func TestSealedPrivateKeyReuse(t *testing.T) {
+	dir := t.TempDir()
+	s := testStateStore(t, dir, 1)
+	if _, err := s.LoadSealedPrivateKey(); !errors.Is(err, ErrStateNotFound) {
+		t.Fatalf("首次运行: 期望 ErrStateNotFound, 得到 %v", err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SealPrivateKey(key); err != nil {
+		t.Fatal(err)
+	}
+	// 重试时（重新打开同一目录、同一绑定密钥）复用同一私钥
+	reused, err := testStateStore(t, dir, 1).LoadSealedPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reused.Equal(key) {
+		t.Fatal("应复用已密封的私钥")
+	}
+	if _, err := testStateStore(t, dir, 2).LoadSealedPrivateKey(); !errors.Is(err, ErrStateUnsealFailed) {
+		t.Fatalf("其他机器: 期望 ErrStateUnsealFailed, 得到 %v", err)
+	}
+	// 可解封但内容不是私钥：报告解码错误，不冒充其他状态
+	if err := s.Put(StatePrivateKey, []byte("not a pem")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.LoadSealedPrivateKey(); err == nil || errors.Is(err, ErrStateUnsealFailed) || errors.Is(err, ErrStateNotFound) {
+		t.Fatalf("无效 PEM 应返回解码错误, 得到 %v", err)
+	}
+}
@@ -0,0 +1,419 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// * 分段 AEAD（STREAM 构造）
+// * 大体积载荷（安装包、数据集）不再整体解密：明文按 StreamSegmentSize 分段，每段独立 AES-256-GCM 加密，
+// *   nonce = 前缀(7, 信封头部) | 段序号(uint32 BE) | 末段标志(1)
+// *   AAD   = 信封头部 | 上层 AAD（与整体加密相同）
+// * 段序号防止重排与重放，末段标志防止截断：流在末段之前结束、末段之后仍有数据，均判定为失败。
+// * 除末段外每段密文长度固定为 StreamSegmentSize+16；末段可以更短（明文为空时仅含标签）。
+// * 解密端每次只持有一段，峰值内存与载荷大小无关。
+
+const (
+	// StreamSegmentSize 每段明文长度
+	StreamSegmentSize = 64 << 10
+
+	streamNoncePrefixSize = 7
+	streamTagSize         = 16
+)
+
+// ErrStreamTruncated 流在末段之前结束
+var ErrStreamTruncated = errors.New("加密载荷被截断")
+
+func newStreamAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// streamNonce nonce = 前缀 | 段序号 | 末段标志
+func streamNonce(dst, prefix []byte, counter uint32, last bool) []byte {
+	dst = append(dst[:0], prefix...)
+	dst = binary.BigEndian.AppendUint32(dst, counter)
+	if last {
+		return append(dst, 1)
+	}
+	return append(dst, 0)
+}
+
+// OpenScriptPayload 读取信封头部并返回解密后的明文流：
+// 分段算法逐段解密；整体 AES-GCM 算法读取全部密文后一次解密（仅适合小载荷）。
+// 解密后的明文只存放在 SecretBuffer 中，读到末尾、出错或 Close 时销毁；调用方须 Close。
+// 压缩载荷例外：compress/flate 的滑动窗口（32 KiB）与解码缓冲区在 Go 堆上，无法锁定或擦除，
+// 最近解压的明文会残留到被回收复用为止。含凭据等敏感内容的脚本不应压缩，gzip 标志只用于安装包等大体积载荷
+func OpenScriptPayload(ssk []byte, r io.Reader, aad []byte) (io.ReadCloser, error) {
+	e, err := ReadEnvelopeHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if e.KeyID != EnvelopeKeyIDSessionScriptKey {
+		return nil, envelopeErr("key_id %q 不是会话脚本密钥", e.KeyID)
+	}
+	var plain secretByteReader
+	switch e.Algorithm {
+	case EnvelopeAlgAES256GCMStream:
+		aead, err := newStreamAEAD(ssk)
+		if err != nil {
+			return nil, err
+		}
+		seg := NewSecretBuffer(StreamSegmentSize + streamTagSize)
+		plain = &streamDecryptReader{
+			aead:   aead,
+			prefix: e.Nonce,
+			aad:    e.AAD(aad),
+			src:    bufio.NewReaderSize(r, StreamSegmentSize+streamTagSize+1),
+			secret: seg,
+			seg:    seg.Bytes(),
+		}
+	default:
+		ct, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(ct) < envelopeAlgorithms[e.Algorithm].tagSize {
+			return nil, envelopeErr("密文被截断")
+		}
+		out, err := AESGCMDecryptSecret(ssk, e.Nonce, ct, e.AAD(aad))
+		if err != nil {
+			return nil, err
+		}
+		plain = &secretReader{secret: out, rest: out.Bytes()}
+	}
+	if e.Flags&EnvelopeFlagGzip != 0 {
+		// plain 实现 io.ByteReader，gzip 直接逐字节读取，不再另建 bufio 缓冲区复制明文
+		zr, err := gzip.NewReader(plain)
+		if err != nil {
+			plain.Close()
+			return nil, err
+		}
+		return &gzipPayloadReader{zr: zr, plain: plain}, nil
+	}
+	return plain, nil
+}
+
+// secretByteReader 解密后的明文流
+type secretByteReader interface {
+	io.ReadCloser
+	io.ByteReader
+}
+
+// gzipPayloadReader 解压明文流；读到末尾、出错或 Close 时关闭解压器并销毁底层明文
+type gzipPayloadReader struct {
+	zr     *gzip.Reader
+	plain  secretByteReader
+	closed bool
+}
+
+func (g *gzipPayloadReader) Read(p []byte) (int, error) {
+	if g.closed {
+		return 0, errPayloadClosed
+	}
+	n, err := g.zr.Read(p)
+	if err != nil {
+		g.zr.Close()
+		g.plain.Close()
+	}
+	return n, err
+}
+
+func (g *gzipPayloadReader) Close() error {
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+	err := g.zr.Close()
+	g.plain.Close()
+	return err
+}
+
+// secretReader 读出 SecretBuffer 中的明文，读完即销毁
+type secretReader struct {
+	secret *SecretBuffer
+	rest   []byte
+	closed bool
+}
+
+func (r *secretReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, errPayloadClosed
+	}
+	if len(r.rest) == 0 {
+		r.secret.Destroy()
+		return 0, io.EOF
+	}
+	n := copy(p, r.rest)
+	r.rest = r.rest[n:]
+	return n, nil
+}
+
+func (r *secretReader) ReadByte() (byte, error) {
+	if r.closed {
+		return 0, errPayloadClosed
+	}
+	if len(r.rest) == 0 {
+		r.secret.Destroy()
+		return 0, io.EOF
+	}
+	b := r.rest[0]
+	r.rest = r.rest[1:]
+	return b, nil
+}
+
+// Close 销毁明文；之后的读取返回错误
+func (r *secretReader) Close() error {
+	r.secret.Destroy()
+	r.rest, r.closed = nil, true
+	return nil
+}
+
+// streamDecryptReader 逐段解密；任何错误都会固定下来，之后的读取返回同一错误
+type streamDecryptReader struct {
+	aead    cipher.AEAD
+	prefix  []byte
+	aad     []byte
+	src     *bufio.Reader
+	secret  *SecretBuffer
+	seg     []byte // 当前段密文缓冲（secret 的内容），解密结果原地写回
+	nonce   []byte
+	plain   []byte // 当前段尚未读出的明文
+	counter uint32
+	done    bool
+	err     error
+}
+
+func (s *streamDecryptReader) Read(p []byte) (int, error) {
+	for len(s.plain) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.done {
+			s.err = io.EOF
+		} else {
+			s.err = s.nextSegment()
+		}
+		if s.err != nil {
+			s.secret.Destroy()
+			s.seg, s.plain = nil, nil
+		}
+	}
+	n := copy(p, s.plain)
+	s.plain = s.plain[n:]
+	return n, nil
+}
+
+func (s *streamDecryptReader) ReadByte() (byte, error) {
+	var b [1]byte
+	if _, err := s.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// Close 销毁当前段；之后的读取返回错误
+func (s *streamDecryptReader) Close() error {
+	s.secret.Destroy()
+	s.seg, s.plain = nil, nil
+	if s.err == nil {
+		s.err = errPayloadClosed
+	}
+	return nil
+}
+
+var errPayloadClosed = errors.New("明文流已关闭")
+
+// nextSegment 读取并解密下一段；满长度的段仅在其后无数据时视为末段
+func (s *streamDecryptReader) nextSegment() error {
+	n, err := io.ReadFull(s.src, s.seg)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, peekErr := s.src.Peek(1); peekErr == io.EOF {
+			last = true
+		} else if peekErr != nil {
+			return peekErr
+		}
+	}
+	if n < streamTagSize {
+		return ErrStreamTruncated
+	}
+	s.nonce = streamNonce(s.nonce, s.prefix, s.counter, last)
+	plain, openErr := s.aead.Open(s.seg[:0], s.nonce, s.seg[:n], s.aad)
+	if openErr != nil {
+		if last {
+			// 以末段标志无法解开：流在中途被截断，或段被重排
+			return fmt.Errorf("%w: 第 %d 段校验失败", ErrStreamTruncated, s.counter)
+		}
+		return fmt.Errorf("加密载荷第 %d 段校验失败: %w", s.counter, openErr)
+	}
+	if s.counter == ^uint32(0) && !last {
+		return errors.New("加密载荷段数超出上限")
+	}
+	s.counter++
+	s.done = last
+	s.plain = plain
+	return nil
+}
+
+// streamEncryptWriter 服务端一侧的参考实现：逐段加密写出；Close 写出末段
+type streamEncryptWriter struct {
+	aead    cipher.AEAD
+	prefix  []byte
+	aad     []byte
+	dst     io.Writer
+	buf     []byte // 待加密明文，最多一段
+	out     []byte
+	nonce   []byte
+	counter uint32
+	closed  bool
+}
+
+// NewStreamEncryptWriter 写出分段算法的信封头部，返回明文写入端；compress 为 true 时先 gzip 压缩
+func NewStreamEncryptWriter(ssk []byte, dst io.Writer, aad []byte, compress bool) (io.WriteCloser, error) {
+	return newStreamEncryptWriter(ssk, dst, aad, compress, rand.Reader)
+}
+
+// SealScriptPayload 服务端一侧的参考实现（整体 AES-256-GCM）：返回 encrypted_script_blob 的原始字节（信封）；
+// compress 为 true 时先 gzip 压缩并置 EnvelopeFlagGzip。与 NewStreamEncryptWriter 一起覆盖 OpenScriptPayload 支持的两种算法，
+// 整体加密需要一次持有全部明文，只适合脚本等小载荷
+func SealScriptPayload(ssk, script, aad []byte, compress bool) ([]byte, error) {
+	return sealScriptPayload(ssk, script, aad, compress, rand.Reader)
+}
+
+// sealScriptPayload 从 random 读取 nonce，测试中可传入固定数据得到确定的输出
+func sealScriptPayload(ssk, script, aad []byte, compress bool, random io.Reader) ([]byte, error) {
+	e := &Envelope{
+		Version:   EnvelopeVersion1,
+		Algorithm: EnvelopeAlgAES256GCM,
+		KeyID:     EnvelopeKeyIDSessionScriptKey,
+		Nonce:     make([]byte, envelopeAlgorithms[EnvelopeAlgAES256GCM].nonceSize),
+	}
+	if compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(script); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		script = buf.Bytes()
+		e.Flags |= EnvelopeFlagGzip
+	}
+	if _, err := io.ReadFull(random, e.Nonce); err != nil {
+		return nil, err
+	}
+	aead, err := newStreamAEAD(ssk)
+	if err != nil {
+		return nil, err
+	}
+	e.Ciphertext = aead.Seal(nil, e.Nonce, script, e.AAD(aad))
+	return e.MarshalBinary()
+}
+
+// newStreamEncryptWriter 从 random 读取 nonce 前缀，测试中可传入固定数据得到确定的输出
+func newStreamEncryptWriter(ssk []byte, dst io.Writer, aad []byte, compress bool, random io.Reader) (io.WriteCloser, error) {
+	aead, err := newStreamAEAD(ssk)
+	if err != nil {
+		return nil, err
+	}
+	e := &Envelope{
+		Version:   EnvelopeVersion1,
+		Algorithm: EnvelopeAlgAES256GCMStream,
+		KeyID:     EnvelopeKeyIDSessionScriptKey,
+		Nonce:     make([]byte, streamNoncePrefixSize),
+	}
+	if compress {
+		e.Flags |= EnvelopeFlagGzip
+	}
+	if _, err := io.ReadFull(random, e.Nonce); err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(e.Header()); err != nil {
+		return nil, err
+	}
+	w := &streamEncryptWriter{
+		aead:   aead,
+		prefix: e.Nonce,
+		aad:    e.AAD(aad),
+		dst:    dst,
+		buf:    make([]byte, 0, StreamSegmentSize),
+		out:    make([]byte, 0, StreamSegmentSize+streamTagSize),
+	}
+	if compress {
+		return &gzipStreamWriter{zw: gzip.NewWriter(w), w: w}, nil
+	}
+	return w, nil
+}
+
+func (w *streamEncryptWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("写入已关闭的加密流")
+	}
+	total := len(p)
+	for len(p) > 0 {
+		// 缓冲区满且仍有数据时，缓冲的一段必然不是末段
+		if len(w.buf) == StreamSegmentSize {
+			if err := w.flush(false); err != nil {
+				return total - len(p), err
+			}
+		}
+		n := copy(w.buf[len(w.buf):StreamSegmentSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+	}
+	return total, nil
+}
+
+func (w *streamEncryptWriter) flush(last bool) error {
+	if w.counter == ^uint32(0) && !last {
+		return errors.New("加密载荷段数超出上限")
+	}
+	w.nonce = streamNonce(w.nonce, w.prefix, w.counter, last)
+	w.out = w.aead.Seal(w.out[:0], w.nonce, w.buf, w.aad)
+	WipeBytes(w.buf)
+	w.buf = w.buf[:0]
+	w.counter++
+	_, err := w.dst.Write(w.out)
+	return err
+}
+
+func (w *streamEncryptWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush(true)
+}
+
+// gzipStreamWriter 先压缩再分段加密
+type gzipStreamWriter struct {
+	zw *gzip.Writer
+	w  *streamEncryptWriter
+}
+
+func (g *gzipStreamWriter) Write(p []byte) (int, error) { return g.zw.Write(p) }
+
+func (g *gzipStreamWriter) Close() error {
+	if err := g.zw.Close(); err != nil {
+		return err
+	}
+	return g.w.Close()
+}
@@ -0,0 +1,207 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+// underlyingSecret 返回明文流底层的 SecretBuffer
+func underlyingSecret(t *testing.T, r io.ReadCloser) *SecretBuffer {
+	t.Helper()
+	if g, ok := r.(*gzipPayloadReader); ok {
+		r = g.plain
+	}
+	switch p := r.(type) {
+	case *secretReader:
+		return p.secret
+	case *streamDecryptReader:
+		return p.secret
+	}
+	t.Fatalf("未知的明文流类型 %T", r)
+	return nil
+}
+
+func TestOpenScriptPayloadReleasesPlaintext(t *testing.T) {
+	ssk := testSSK(t)
+	aad := []byte("aad")
+	payload := bytes.Repeat([]byte("echo configured\n"), StreamSegmentSize/16+3)
+	blobs := map[string][]byte{
+		"整体加密":    encryptScriptBlob(t, rand.Reader, ssk, payload, aad, false),
+		"整体加密+压缩": encryptScriptBlob(t, rand.Reader, ssk, payload, aad, true),
+		"分段加密":    encryptStreamBlob(t, rand.Reader, ssk, payload, aad, false),
+		"分段加密+压缩": encryptStreamBlob(t, rand.Reader, ssk, payload, aad, true),
+	}
+	for name, blob := range blobs {
+		t.Run(name+"/读到末尾", func(t *testing.T) {
+			r, err := OpenScriptPayload(ssk, bytes.NewReader(blob), aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil || !bytes.Equal(got, payload) {
+				t.Fatalf("解密结果不一致: %v", err)
+			}
+			if underlyingSecret(t, r).Bytes() != nil {
+				t.Fatal("读到末尾后明文缓冲区未销毁")
+			}
+			if err := r.Close(); err != nil {
+				t.Fatalf("重复关闭: %v", err)
+			}
+		})
+		t.Run(name+"/提前关闭", func(t *testing.T) {
+			r, err := OpenScriptPayload(ssk, bytes.NewReader(blob), aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.ReadFull(r, make([]byte, 100)); err != nil {
+				t.Fatal(err)
+			}
+			r.Close()
+			if underlyingSecret(t, r).Bytes() != nil {
+				t.Fatal("关闭后明文缓冲区未销毁")
+			}
+			if _, err := r.Read(make([]byte, 1)); err == nil {
+				t.Fatal("关闭后仍可读取")
+			}
+		})
+	}
+}
+
+func TestOpenScriptPayloadTruncatedGzip(t *testing.T) {
+	ssk := testSSK(t)
+	aad := []byte("aad")
+	// 随机内容不可压缩，压缩后仍跨越多段
+	payload := make([]byte, 2*StreamSegmentSize)
+	rand.Read(payload)
+	blob := encryptStreamBlob(t, rand.Reader, ssk, payload, aad, true)
+	r, err := OpenScriptPayload(ssk, bytes.NewReader(blob[:len(blob)-1]), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrStreamTruncated) {
+		t.Fatalf("期望截断错误, 得到 %v", err)
+	}
+	if underlyingSecret(t, r).Bytes() != nil {
+		t.Fatal("出错后明文缓冲区未销毁")
+	}
+}
+
+// streamSegments 拆出分段载荷的信封头部与各段密文
+func streamSegments(t *testing.T, blob []byte) (header []byte, segs [][]byte) {
+	t.Helper()
+	e, err := ReadEnvelopeHeader(bytes.NewReader(blob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header = e.Header()
+	rest := blob[len(header):]
+	for len(rest) > StreamSegmentSize+streamTagSize {
+		segs = append(segs, rest[:StreamSegmentSize+streamTagSize])
+		rest = rest[StreamSegmentSize+streamTagSize:]
+	}
+	return header, append(segs, rest)
+}
+
+func joinStream(header []byte, segs ...[]byte) []byte {
+	return bytes.Join(append([][]byte{header}, segs...), nil)
+}
+
+// 多段、未压缩的流：重排、截断、追加均须失败，截断类错误为 ErrStreamTruncated
+func TestStreamSegmentTamper(t *testing.T) {
+	ssk := testSSK(t)
+	aad := []byte("aad")
+	payload := make([]byte, 3*StreamSegmentSize-100)
+	rand.Read(payload)
+	header, segs := streamSegments(t, encryptStreamBlob(t, rand.Reader, ssk, payload, aad, false))
+	if len(segs) != 3 || len(segs[2]) != StreamSegmentSize-100+streamTagSize {
+		t.Fatalf("分段布局不符: %d 段", len(segs))
+	}
+	exact := make([]byte, 2*StreamSegmentSize)
+	rand.Read(exact)
+	exactHeader, exactSegs := streamSegments(t, encryptStreamBlob(t, rand.Reader, ssk, exact, aad, false))
+	if len(exactSegs) != 2 || len(exactSegs[1]) != StreamSegmentSize+streamTagSize {
+		t.Fatalf("整段倍数的明文不应多出空末段: %d 段", len(exactSegs))
+	}
+
+	cases := []struct {
+		name      string
+		blob      []byte
+		truncated bool // 期望 ErrStreamTruncated；否则只要求失败
+	}{
+		{"交换前两段", joinStream(header, segs[1], segs[0], segs[2]), false},
+		{"交换后两段", joinStream(header, segs[0], segs[2], segs[1]), false},
+		{"在段边界截断", joinStream(header, segs[0]), true},
+		{"在段中间截断", joinStream(header, segs[0], segs[1][:1000]), true},
+		{"丢弃末段", joinStream(header, segs[0], segs[1]), true},
+		{"末段之后追加数据", joinStream(header, segs[0], segs[1], segs[2], []byte("x")), false},
+		{"末段之后追加整段", joinStream(header, segs[0], segs[1], segs[2], segs[1]), false},
+		{"整段倍数/丢弃末段", joinStream(exactHeader, exactSegs[0]), true},
+		{"整段倍数/末段之后追加数据", joinStream(exactHeader, exactSegs[0], exactSegs[1], []byte("x")), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := openScriptBlob(ssk, c.blob, aad)
+			if err == nil {
+				t.Fatalf("篡改后的流解密成功（%d 字节）", len(got))
+			}
+			if c.truncated && !errors.Is(err, ErrStreamTruncated) {
+				t.Fatalf("期望截断错误, 得到 %v", err)
+			}
+		})
+	}
+
+	for _, c := range []struct {
+		name string
+		blob []byte
+		want []byte
+	}{
+		{"原样", joinStream(header, segs...), payload},
+		{"整段倍数", joinStream(exactHeader, exactSegs...), exact},
+	} {
+		got, err := openScriptBlob(ssk, c.blob, aad)
+		if err != nil || !bytes.Equal(got, c.want) {
+			t.Fatalf("%s: 解密结果不一致: %v", c.name, err)
+		}
+	}
+}
+
+// 解密端任何时刻只持有一段：密文缓冲与预读缓冲都以一段为上限，未读明文不超过一段
+func TestStreamDecryptHoldsOneSegment(t *testing.T) {
+	ssk := testSSK(t)
+	aad := []byte("aad")
+	payload := make([]byte, 4*StreamSegmentSize+123)
+	rand.Read(payload)
+	r, err := OpenScriptPayload(ssk, bytes.NewReader(encryptStreamBlob(t, rand.Reader, ssk, payload, aad, false)), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	s := r.(*streamDecryptReader)
+	if s.src.Size() > StreamSegmentSize+streamTagSize+1 {
+		t.Fatalf("预读缓冲 %d 字节，超过一段", s.src.Size())
+	}
+	var got []byte
+	buf := make([]byte, 10000)
+	for {
+		n, err := s.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.secret.Len() != StreamSegmentSize+streamTagSize || len(s.seg) > StreamSegmentSize+streamTagSize {
+			t.Fatalf("段缓冲 %d 字节，超过一段", s.secret.Len())
+		}
+		if len(s.plain) > StreamSegmentSize {
+			t.Fatalf("未读明文 %d 字节，超过一段", len(s.plain))
+		}
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatal("解密结果不一致")
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"os"
 )
@@ -19,7 +21,12 @@ func main() {
 	// 转换为base64编码
 	saltBase64 := base64.StdEncoding.EncodeToString(salt)
 
+	// 盐值代号：盐值 SHA-256 的前 4 字节，轮换盐值时随之改变，服务端据此区分盐值批次
+	sum := sha256.Sum256([]byte(saltBase64))
+	generation := hex.EncodeToString(sum[:4])
+
 	// 输出编译命令
-	fmt.Printf("生成的随机盐值: %s\n\n", saltBase64)
-	fmt.Printf("编译命令:\ngo build -ldflags \"-X main.SaltForPrivateKeyEncryption=%s\" ./cmd/main.go\n", saltBase64)
+	fmt.Printf("生成的随机盐值: %s\n", saltBase64)
+	fmt.Printf("盐值代号: %s\n\n", generation)
+	fmt.Printf("编译命令:\ngo build -ldflags \"-X main.SaltForPrivateKeyEncryption=%s -X main.SaltGeneration=%s\" ./cmd/main.go\n", saltBase64, generation)
 }
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+func main() {
+	// 生成服务端响应签名用的 Ed25519 密钥对
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "生成密钥失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	// 私钥（32 字节种子）仅配置在服务端，公钥编译进客户端
+	fmt.Printf("服务端签名私钥（种子，妥善保管）: %s\n", base64.StdEncoding.EncodeToString(priv.Seed()))
+	fmt.Printf("客户端验证公钥: %s\n\n", base64.StdEncoding.EncodeToString(pub))
+	fmt.Printf("编译命令:\ngo build -ldflags \"-X main.ServerVerifyKey=%s\" ./cmd/main.go\n", base64.StdEncoding.EncodeToString(pub))
+}